@@ -0,0 +1,123 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+
+    "go-api/pkg/controllers"
+    "go-api/pkg/dataset"
+    "go-api/pkg/db"
+    "go-api/pkg/seed"
+)
+
+// runCommand runs a maintenance subcommand instead of the HTTP server.
+func runCommand(database *mongo.Database, name string, args []string) error {
+    switch name {
+    case "import-dataset":
+        return importDataset(database, args)
+    case "seed":
+        return seedDatabase(database, args)
+    }
+    return fmt.Errorf("unknown command %q (available: import-dataset, seed)", name)
+}
+
+// importDataset loads raw dataset dumps (mongoexport JSON or mongodump BSON).
+// Running it again with the same dumps updates documents in place.
+func importDataset(database *mongo.Database, args []string) error {
+    fs := flag.NewFlagSet("import-dataset", flag.ContinueOnError)
+    categories := fs.String("categories", "", "categories dump (.json or .bson)")
+    species := fs.String("species", "", "species dump (.json or .bson)")
+    animals := fs.String("animals", "", "animals dump (.json or .bson)")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    if *categories == "" && *species == "" && *animals == "" {
+        fs.Usage()
+        return fmt.Errorf("at least one of -categories, -species, -animals is required")
+    }
+
+    var files dataset.Files
+    for _, f := range []struct {
+        path string
+        dst  *[]bson.M
+    }{{*categories, &files.Categories}, {*species, &files.Species}, {*animals, &files.Animals}} {
+        if f.path == "" {
+            continue
+        }
+        docs, err := readDump(f.path)
+        if err != nil {
+            return fmt.Errorf("%s: %w", f.path, err)
+        }
+        *f.dst = docs
+    }
+
+    im := dataset.NewImporter(database)
+    audit := controllers.NewAuditLog(database)
+    im.Audit = func(collection, action string, id primitive.ObjectID, before, after bson.M) {
+        audit.RecordAs("import-dataset", "", collection, action, id, before, after)
+    }
+    rep, err := im.Run(db.Ctx, files)
+    if err != nil {
+        return err
+    }
+    enc := json.NewEncoder(os.Stdout)
+    enc.SetIndent("", "  ")
+    return enc.Encode(rep)
+}
+
+func readDump(path string) ([]bson.M, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    return dataset.ReadFile(path, f)
+}
+
+// seedSizes are the animal counts behind the -size presets.
+var seedSizes = map[string]int{"small": 50, "medium": 500, "large": 5000}
+
+// seedDatabase fills the database with generated fixtures. Ids derive from the
+// seed, so seeding twice on the same day with the same seed needs -reset.
+func seedDatabase(database *mongo.Database, args []string) error {
+    fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+    size := fs.String("size", "small", "data set size: small, medium or large")
+    animals := fs.Int("animals", 0, "number of animals (overrides -size)")
+    randomSeed := fs.Int64("seed", 1, "random seed; 0 picks one from the clock")
+    adopted := fs.Float64("adopted", 0.3, "share of adopted animals (0-1)")
+    reset := fs.Bool("reset", false, "delete existing animals, species and categories first")
+    if err := fs.Parse(args); err != nil {
+        return err
+    }
+    n, ok := seedSizes[*size]
+    if !ok {
+        return fmt.Errorf("unknown size %q (small, medium or large)", *size)
+    }
+    if *animals > 0 {
+        n = *animals
+    }
+    if *adopted < 0 || *adopted > 1 {
+        return fmt.Errorf("-adopted must be between 0 and 1")
+    }
+    if *randomSeed == 0 {
+        *randomSeed = time.Now().UnixNano()
+    }
+
+    fx := seed.Generate(seed.Options{Animals: n, Seed: *randomSeed, AdoptedRatio: *adopted})
+    if err := seed.Insert(db.Ctx, database, fx, *reset); err != nil {
+        if mongo.IsDuplicateKeyError(err) {
+            return fmt.Errorf("%w (data from this seed already exists; use -reset or another -seed)", err)
+        }
+        return err
+    }
+    fmt.Printf("seeded %d categories, %d species and %d animals (seed %d)\n",
+        len(fx.Categories), len(fx.Species), len(fx.Animals), *randomSeed)
+    return nil
+}
@@ -13,6 +13,7 @@ import (
 
     "go-api/pkg/config"
     "go-api/pkg/db"
+    "go-api/pkg/middleware"
     "go-api/pkg/routes"
 )
 
@@ -36,7 +37,23 @@ func main() {
     }
     defer client.Disconnect(db.Ctx)
 
+    // Subcommands (e.g. import-dataset) run against the database and exit.
+    if len(os.Args) > 1 {
+        if err := runCommand(client.Database(cfg.DatabaseName), os.Args[1], os.Args[2:]); err != nil {
+            log.Fatalf("%s: %v", os.Args[1], err)
+        }
+        return
+    }
+
+    // Stored sort keys (sort=species, sort=category) are kept up to date on
+    // every write; recompute them once so documents written by older
+    // versions or directly in the database sort correctly too.
+    if err := db.BackfillSortKeys(db.Ctx, client.Database(cfg.DatabaseName)); err != nil {
+        log.Printf("sort keys: backfill failed, run POST /api/v1/maintenance/backfill-sort-keys: %v", err)
+    }
+
     r := gin.Default()
+    r.Use(middleware.RequestID())
 
     // health
     r.GET("/health", func(c *gin.Context) {
@@ -63,7 +80,7 @@ func main() {
     r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL("/openapi/doc.json")))
 
     api := r.Group("/api/v1")
-    routes.RegisterAnimalRoutes(api, client, cfg.DatabaseName)
+    routes.RegisterAnimalRoutes(api, client, cfg)
 
     port := cfg.Port
     if p := os.Getenv("PORT"); p != "" {
@@ -8,6 +8,12 @@ type Config struct {
     Port         string
     MongoURI     string
     DatabaseName string
+    // AdminToken guards admin endpoints via the X-Admin-Token header; empty disables those endpoints.
+    AdminToken   string
+    // LegacyPut keeps PUT as a partial update for clients that rely on it.
+    LegacyPut    bool
+    // LenientQuery ignores invalid list query parameters instead of returning 400.
+    LenientQuery bool
 }
 
 func Load() Config {
@@ -15,6 +21,9 @@ func Load() Config {
         Port:         getenv("PORT", "8080"),
         MongoURI:     getenv("MONGO_URI", "mongodb://localhost:27017"),
         DatabaseName: getenv("MONGO_DB", "goapi"),
+        AdminToken:   getenv("ADMIN_TOKEN", ""),
+        LegacyPut:    getenv("LEGACY_PUT", "false") == "true",
+        LenientQuery: getenv("LENIENT_QUERY", "false") == "true",
     }
     return cfg
 }
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+
+    "go-api/pkg/db"
+    "go-api/pkg/models"
+    "go-api/pkg/utils"
+)
+
+// BulkCreateAnimals godoc
+// @Summary Create many animals in one request
+// @Description Each item is validated like POST /animals. Invalid items are reported per item
+// @Description without failing the batch; with ordered=true processing stops at the first failure.
+// @Tags animals
+// @Accept json
+// @Produce json
+// @Param ordered query bool false "Stop at the first failing item"
+// @Param animals body []models.Animal true "Animals"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /animals/bulk [post]
+func (ac *AnimalController) BulkCreateAnimals(c *gin.Context) {
+    ordered, err := parseOrdered(c)
+    if err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    var items []json.RawMessage
+    if err := c.ShouldBindJSON(&items); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    if err := checkBulkSize(len(items)); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+
+    batch := newBulkBatch(len(items), ordered)
+    docs := make([]models.Animal, len(items))
+    now := time.Now().UTC()
+    for i, item := range items {
+        if batch.Stopped() {
+            break
+        }
+        var body animalInput
+        if err := json.Unmarshal(item, &body); err != nil {
+            batch.Fail(i, http.StatusBadRequest, err)
+            continue
+        }
+        in := body.model()
+        if err := validate.Struct(in); err != nil {
+            batch.Fail(i, http.StatusBadRequest, err)
+            continue
+        }
+        in.ID = primitive.NewObjectID()
+        in.CreatedAt = now
+        in.UpdatedAt = now
+        in.Version = 1
+        stampAdoption(nil, &in, now)
+        docs[i] = in
+        batch.Queue(i, http.StatusCreated, in.ID.Hex(), mongo.NewInsertOneModel().SetDocument(in))
+    }
+    if err := batch.Apply(ac.Collection); err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    written := batch.Written()
+    created := make([]interface{}, 0, len(written))
+    for _, i := range written {
+        created = append(created, docs[i].ID)
+        if after, err := toDoc(docs[i]); err == nil {
+            ac.Audit.Record(c, "animals", auditCreate, docs[i].ID, nil, after)
+        }
+    }
+    refreshSortKeys(ac.Collection, db.SpeciesSort, created...)
+    batch.Respond(c)
+}
+
+// BulkPatchAnimals godoc
+// @Summary Merge-patch many animals in one request
+// @Description Each item is {"id": "...", "patch": {...}} with RFC 7396 semantics, validated like PATCH /animals/{id}.
+// @Tags animals
+// @Accept json
+// @Produce json
+// @Param ordered query bool false "Stop at the first failing item"
+// @Param patches body []object true "Patches"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /animals/bulk [patch]
+func (ac *AnimalController) BulkPatchAnimals(c *gin.Context) {
+    ordered, err := parseOrdered(c)
+    if err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    type patchItem struct {
+        ID    string          `json:"id"`
+        Patch json.RawMessage `json:"patch"`
+    }
+    var items []patchItem
+    if err := c.ShouldBindJSON(&items); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    if err := checkBulkSize(len(items)); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    hexes := make([]string, len(items))
+    for i, it := range items {
+        hexes[i] = it.ID
+    }
+    ids, idErrs := parseIDs(hexes)
+    current, err := fetchByIDs(ac.Collection, ids)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+
+    type pending struct {
+        before, after  bson.M
+        speciesChanged bool
+    }
+    batch := newBulkBatch(len(items), ordered)
+    changes := make([]pending, len(items))
+    // one stamp for the batch: a later patch of the same document must not
+    // make an earlier one look lost
+    now := time.Now().UTC()
+    for i, it := range items {
+        if batch.Stopped() {
+            break
+        }
+        if idErrs[i] != nil {
+            batch.Fail(i, http.StatusBadRequest, idErrs[i])
+            continue
+        }
+        raw, ok := current[ids[i]]
+        if !ok {
+            batch.Fail(i, http.StatusNotFound, errors.New("not found"))
+            continue
+        }
+        cur, err := mapAnimal(raw)
+        if err != nil {
+            batch.Fail(i, http.StatusInternalServerError, err)
+            continue
+        }
+        var next models.Animal
+        if err := applyPatch(cur, it.Patch, false, &next); err != nil {
+            batch.Fail(i, http.StatusBadRequest, err)
+            continue
+        }
+        if next.Location != nil && next.Location.Type == "" {
+            next.Location.Type = "Point"
+        }
+        if err := validate.Struct(next); err != nil {
+            batch.Fail(i, http.StatusBadRequest, err)
+            continue
+        }
+        stampAdoption(&cur, &next, now)
+        set, unset, err := patchUpdate(cur, next)
+        if err != nil {
+            batch.Fail(i, http.StatusInternalServerError, err)
+            continue
+        }
+        if len(set) == 0 && len(unset) == 0 {
+            batch.Done(i, http.StatusOK, it.ID)
+            continue
+        }
+        set["updatedAt"] = now
+        update := bson.M{"$set": set, "$inc": bson.M{"version": int64(1)}}
+        if len(unset) > 0 {
+            update["$unset"] = unset
+        }
+        after, err := applyUpdate(raw, set, unset)
+        if err != nil {
+            batch.Fail(i, http.StatusInternalServerError, err)
+            continue
+        }
+        _, speciesChanged := set["species"]
+        changes[i] = pending{before: raw, after: after, speciesChanged: speciesChanged}
+        // Later items may target the same document; they must see this change.
+        current[ids[i]] = after
+        filter := currentVersionFilter(ids[i], docVersion(raw))
+        batch.Queue(i, http.StatusOK, it.ID, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update))
+    }
+    // a patch landed if the document carries its version and this batch's stamp
+    stamp := primitive.NewDateTimeFromTime(now)
+    landed := func(i int, doc bson.M) bool {
+        return doc != nil && docVersion(doc) >= docVersion(changes[i].after) && doc["updatedAt"] == stamp
+    }
+    if err := batch.ApplyGuarded(ac.Collection, func(i int) primitive.ObjectID { return ids[i] }, landed); err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    var moved []interface{}
+    for _, i := range batch.Written() {
+        ac.Audit.Record(c, "animals", auditUpdate, ids[i], changes[i].before, changes[i].after)
+        if changes[i].speciesChanged {
+            moved = append(moved, ids[i])
+        }
+    }
+    refreshSortKeys(ac.Collection, db.SpeciesSort, moved...)
+    batch.Respond(c)
+}
+
+// BulkDeleteAnimals godoc
+// @Summary Delete many animals in one request
+// @Tags animals
+// @Accept json
+// @Produce json
+// @Param ordered query bool false "Stop at the first failing item"
+// @Param ids body object true "{\"ids\": [\"...\"]}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /animals/bulk [delete]
+func (ac *AnimalController) BulkDeleteAnimals(c *gin.Context) {
+    ordered, err := parseOrdered(c)
+    if err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    var body struct {
+        IDs []string `json:"ids"`
+    }
+    if err := c.ShouldBindJSON(&body); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    if err := checkBulkSize(len(body.IDs)); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    ids, idErrs := parseIDs(body.IDs)
+    current, err := fetchByIDs(ac.Collection, ids)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+
+    batch := newBulkBatch(len(ids), ordered)
+    queued := map[primitive.ObjectID]bool{}
+    for i, oid := range ids {
+        if batch.Stopped() {
+            break
+        }
+        if idErrs[i] != nil {
+            batch.Fail(i, http.StatusBadRequest, idErrs[i])
+            continue
+        }
+        raw, ok := current[oid]
+        if !ok || queued[oid] {
+            batch.Fail(i, http.StatusNotFound, errors.New("not found"))
+            continue
+        }
+        queued[oid] = true
+        filter := currentVersionFilter(oid, docVersion(raw))
+        batch.Queue(i, http.StatusNoContent, oid.Hex(), mongo.NewDeleteOneModel().SetFilter(filter))
+    }
+    gone := func(i int, doc bson.M) bool { return doc == nil }
+    if err := batch.ApplyGuarded(ac.Collection, func(i int) primitive.ObjectID { return ids[i] }, gone); err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    for _, i := range batch.Written() {
+        ac.Audit.Record(c, "animals", auditDelete, ids[i], current[ids[i]], nil)
+    }
+    batch.Respond(c)
+}
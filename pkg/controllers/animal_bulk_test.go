@@ -0,0 +1,172 @@
+package controllers
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// storedAnimal is a valid stored animal at version 1.
+func storedAnimal(id primitive.ObjectID) bson.D {
+    return bson.D{
+        {Key: "_id", Value: id}, {Key: "name", Value: "Misu"}, {Key: "species", Value: primitive.NewObjectID().Hex()},
+        {Key: "age", Value: int32(2)}, {Key: "adopted", Value: false}, {Key: "version", Value: int64(1)},
+    }
+}
+
+func cursorOf(mt *mtest.T, docs ...bson.D) bson.D {
+    return mtest.CreateCursorResponse(0, mt.Coll.Database().Name()+"."+mt.Coll.Name(), mtest.FirstBatch, docs...)
+}
+
+// runBulk calls handler with body and returns the statuses of the items.
+func runBulk(t *testing.T, handler gin.HandlerFunc, method, target, body string) []int {
+    t.Helper()
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+    c.Request.Header.Set("Content-Type", "application/json")
+    handler(c)
+    if w.Code != http.StatusOK {
+        t.Fatalf("%s %s: status %d: %s", method, target, w.Code, w.Body)
+    }
+    var resp struct {
+        Results []bulkResult `json:"results"`
+    }
+    if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+        t.Fatal(err)
+    }
+    statuses := make([]int, len(resp.Results))
+    for i, r := range resp.Results {
+        statuses[i] = r.Status
+    }
+    return statuses
+}
+
+// writeCommands returns the started commands named name.
+func writeCommands(mt *mtest.T, name string) []bson.Raw {
+    var out []bson.Raw
+    for _, e := range mt.GetAllStartedEvents() {
+        if e.CommandName == name {
+            out = append(out, e.Command)
+        }
+    }
+    return out
+}
+
+func sameStatuses(got, want []int) bool {
+    if len(got) != len(want) {
+        return false
+    }
+    for i := range got {
+        if got[i] != want[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func TestBulkOrderedStopsAtTheBadItem(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    a, b := primitive.NewObjectID(), primitive.NewObjectID()
+
+    mt.Run("patch", func(mt *mtest.T) {
+        mt.AddMockResponses(
+            cursorOf(mt, storedAnimal(a), storedAnimal(b)),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+        )
+        ac := &AnimalController{Collection: mt.Coll}
+        body := `[{"id":"` + a.Hex() + `","patch":{"age":3}},{"id":"nope","patch":{}},{"id":"` + b.Hex() + `","patch":{"age":4}}]`
+        got := runBulk(t, ac.BulkPatchAnimals, "PATCH", "/animals/bulk?ordered=true", body)
+        if want := []int{200, 400, 424}; !sameStatuses(got, want) {
+            t.Errorf("statuses = %v, want %v", got, want)
+        }
+        updates := writeCommands(mt, "update")
+        if len(updates) != 1 {
+            t.Fatalf("%d update commands, want 1", len(updates))
+        }
+        if n, _ := updates[0].Lookup("updates").Array().Values(); len(n) != 1 {
+            t.Errorf("%d updates sent, want 1", len(n))
+        }
+    })
+
+    mt.Run("delete", func(mt *mtest.T) {
+        mt.AddMockResponses(
+            cursorOf(mt, storedAnimal(a), storedAnimal(b)),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+        )
+        ac := &AnimalController{Collection: mt.Coll}
+        missing := primitive.NewObjectID()
+        body := `{"ids":["` + a.Hex() + `","` + missing.Hex() + `","` + b.Hex() + `"]}`
+        got := runBulk(t, ac.BulkDeleteAnimals, "DELETE", "/animals/bulk?ordered=true", body)
+        if want := []int{204, 404, 424}; !sameStatuses(got, want) {
+            t.Errorf("statuses = %v, want %v", got, want)
+        }
+        if n := len(writeCommands(mt, "delete")); n != 1 {
+            t.Errorf("%d delete commands, want 1", n)
+        }
+    })
+}
+
+func TestBulkGuardedMisses(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    a, b, c := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+    mt.Run("delete", func(mt *mtest.T) {
+        changed := storedAnimal(b)
+        changed[len(changed)-1].Value = int64(2)
+        mt.AddMockResponses(
+            cursorOf(mt, storedAnimal(a), storedAnimal(b), storedAnimal(c)),
+            // one write in one round trip for all three; only one matched
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+            // read back: a is gone, b was modified meanwhile, c is still there
+            cursorOf(mt, changed, storedAnimal(c)),
+        )
+        ac := &AnimalController{Collection: mt.Coll}
+        body := `{"ids":["` + a.Hex() + `","` + b.Hex() + `","` + c.Hex() + `"]}`
+        got := runBulk(t, ac.BulkDeleteAnimals, "DELETE", "/animals/bulk", body)
+        if want := []int{204, 412, 412}; !sameStatuses(got, want) {
+            t.Errorf("statuses = %v, want %v", got, want)
+        }
+        if n := len(writeCommands(mt, "delete")); n != 1 {
+            t.Errorf("%d delete commands, want 1", n)
+        }
+    })
+
+    mt.Run("patch", func(mt *mtest.T) {
+        mt.AddMockResponses(
+            cursorOf(mt, storedAnimal(a), storedAnimal(b)),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+            // read back: a was deleted meanwhile, b is at another version
+            cursorOf(mt, storedAnimal(b)),
+        )
+        ac := &AnimalController{Collection: mt.Coll}
+        body := `[{"id":"` + a.Hex() + `","patch":{"age":3}},{"id":"` + b.Hex() + `","patch":{"age":4}}]`
+        got := runBulk(t, ac.BulkPatchAnimals, "PATCH", "/animals/bulk", body)
+        if want := []int{404, 412}; !sameStatuses(got, want) {
+            t.Errorf("statuses = %v, want %v", got, want)
+        }
+    })
+
+    mt.Run("all matched", func(mt *mtest.T) {
+        mt.AddMockResponses(
+            cursorOf(mt, storedAnimal(a), storedAnimal(b)),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}),
+        )
+        ac := &AnimalController{Collection: mt.Coll}
+        body := `[{"id":"` + a.Hex() + `","patch":{"age":3}},{"id":"` + b.Hex() + `","patch":{"age":4}}]`
+        got := runBulk(t, ac.BulkPatchAnimals, "PATCH", "/animals/bulk", body)
+        if want := []int{200, 200}; !sameStatuses(got, want) {
+            t.Errorf("statuses = %v, want %v", got, want)
+        }
+        if n := len(writeCommands(mt, "find")); n != 1 {
+            t.Errorf("%d find commands, want only the initial read", n)
+        }
+    })
+}
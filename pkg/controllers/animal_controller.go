@@ -2,9 +2,8 @@ package controllers
 
 import (
     "errors"
+    "log"
     "net/http"
-    "strconv"
-    "strings"
     "time"
 
     "github.com/gin-gonic/gin"
@@ -16,6 +15,7 @@ import (
 
     "go-api/pkg/db"
     "go-api/pkg/models"
+    "go-api/pkg/query"
     "go-api/pkg/utils"
 )
 
@@ -23,40 +23,33 @@ var validate = validator.New()
 
 type AnimalController struct {
     Collection *mongo.Collection
+    Audit      *AuditLog
+    // LegacyPut makes PUT behave as a partial update for every client.
+    LegacyPut bool
 }
 
 func NewAnimalController(client *mongo.Client, dbName string) *AnimalController {
-    return &AnimalController{Collection: client.Database(dbName).Collection("animals")}
+    database := client.Database(dbName)
+    return &AnimalController{Collection: database.Collection("animals"), Audit: NewAuditLog(database)}
 }
 
-// CreateAnimal godoc
-// @Summary Create a new animal
-// @Tags animals
-// @Accept json
-// @Produce json
-// @Param animal body models.Animal true "Animal"
-// @Success 201 {object} models.Animal
-// @Failure 400 {object} map[string]string
-// @Router /animals [post]
-func (ac *AnimalController) CreateAnimal(c *gin.Context) {
-    // Accept both our schema and dataset-style fields
-    type animalIn struct {
-        Name       string `json:"name"`
-        AnimalName string `json:"animal_name"`
-        Species    string `json:"species"`
-        Birthdate  string `json:"birthdate"`
-        Age        *int   `json:"age"`
-        Adopted    *bool  `json:"adopted"`
-        Image      string           `json:"image"`
-        Owner      string           `json:"owner"`
-        Location   *models.GeoPoint `json:"location"`
-    }
-    var body animalIn
-    if err := c.ShouldBindJSON(&body); err != nil {
-        utils.BadRequest(c, err)
-        return
-    }
+// animalInput accepts both our schema and dataset-style fields.
+type animalInput struct {
+    Name       string `json:"name"`
+    AnimalName string `json:"animal_name"`
+    Species    string `json:"species"`
+    Birthdate  string `json:"birthdate"`
+    Age        *int   `json:"age"`
+    Adopted    *bool  `json:"adopted"`
+    Image      string           `json:"image"`
+    Owner      string           `json:"owner"`
+    Location   *models.GeoPoint `json:"location"`
+    AdoptedAt  *time.Time       `json:"adoptedAt"`
+}
 
+// model converts the input to an Animal. Fields that are not provided keep
+// their zero value.
+func (body animalInput) model() models.Animal {
     var in models.Animal
     if body.Name != "" {
         in.Name = body.Name
@@ -74,12 +67,8 @@ func (ac *AnimalController) CreateAnimal(c *gin.Context) {
     if body.Adopted != nil {
         in.Adopted = *body.Adopted
     }
-    if body.Image != "" {
-        in.Image = body.Image
-    }
-    if body.Owner != "" {
-        in.Owner = body.Owner
-    }
+    in.Image = body.Image
+    in.Owner = body.Owner
     if body.Location != nil {
         // Default to GeoJSON Point if not specified
         gp := *body.Location
@@ -88,7 +77,52 @@ func (ac *AnimalController) CreateAnimal(c *gin.Context) {
         }
         in.Location = &gp
     }
+    in.AdoptedAt = body.AdoptedAt
+    return in
+}
+
+// stampAdoption maintains adoptedAt across a write from prev (nil on create)
+// to next: it is set to now when the animal becomes adopted, unless the
+// client supplied a date, and otherwise carried over from prev.
+func stampAdoption(prev, next *models.Animal, now time.Time) {
+    var before *time.Time
+    wasAdopted := false
+    if prev != nil {
+        before, wasAdopted = prev.AdoptedAt, prev.Adopted
+    }
+    if next.AdoptedAt == nil {
+        next.AdoptedAt = before
+    }
+    if next.Adopted && !wasAdopted && sameTime(next.AdoptedAt, before) {
+        t := now
+        next.AdoptedAt = &t
+    }
+}
 
+func sameTime(a, b *time.Time) bool {
+    if a == nil || b == nil {
+        return a == b
+    }
+    return a.Equal(*b)
+}
+
+// CreateAnimal godoc
+// @Summary Create a new animal
+// @Tags animals
+// @Accept json
+// @Produce json
+// @Param animal body models.Animal true "Animal"
+// @Success 201 {object} models.Animal
+// @Failure 400 {object} map[string]string
+// @Router /animals [post]
+func (ac *AnimalController) CreateAnimal(c *gin.Context) {
+    var body animalInput
+    if err := c.ShouldBindJSON(&body); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+
+    in := body.model()
     if err := validate.Struct(in); err != nil {
         utils.BadRequest(c, err)
         return
@@ -97,6 +131,8 @@ func (ac *AnimalController) CreateAnimal(c *gin.Context) {
     now := time.Now().UTC()
     in.CreatedAt = now
     in.UpdatedAt = now
+    in.Version = 1
+    stampAdoption(nil, &in, now)
 
     res, err := ac.Collection.InsertOne(db.Ctx, in)
     if err != nil {
@@ -104,6 +140,11 @@ func (ac *AnimalController) CreateAnimal(c *gin.Context) {
         return
     }
     in.ID = res.InsertedID.(primitive.ObjectID)
+    refreshSortKeys(ac.Collection, db.SpeciesSort, in.ID)
+    if after, err := toDoc(in); err == nil {
+        ac.Audit.Record(c, "animals", auditCreate, in.ID, nil, after)
+    }
+    setETag(c, in.Version)
     c.JSON(http.StatusCreated, in)
 }
 
@@ -112,7 +153,13 @@ func (ac *AnimalController) CreateAnimal(c *gin.Context) {
 // @Tags animals
 // @Produce json
 // @Param id path string true "Animal ID"
+// @Param fields query string false "Comma-separated fields to return"
 // @Success 200 {object} models.Animal
+// @Param If-None-Match header string false "ETag held by the client"
+// @Param If-Modified-Since header string false "HTTP date held by the client"
+// @Header 200 {string} ETag "Document version"
+// @Header 200 {string} Last-Modified "updatedAt"
+// @Success 304 {string} string ""
 // @Failure 404 {object} map[string]string
 // @Router /animals/{id} [get]
 func (ac *AnimalController) GetAnimal(c *gin.Context) {
@@ -122,8 +169,12 @@ func (ac *AnimalController) GetAnimal(c *gin.Context) {
         utils.BadRequest(c, errors.New("invalid id"))
         return
     }
+    fields := parseFields(c, animalFieldPaths)
+    if invalidParams(c) {
+        return
+    }
     var raw bson.M
-    err = ac.Collection.FindOne(db.Ctx, bson.M{"_id": oid}).Decode(&raw)
+    err = ac.Collection.FindOne(db.Ctx, bson.M{"_id": oid}, fields.FindOne()).Decode(&raw)
     if err != nil {
         if errors.Is(err, mongo.ErrNoDocuments) {
             utils.NotFound(c)
@@ -132,157 +183,263 @@ func (ac *AnimalController) GetAnimal(c *gin.Context) {
         utils.ServerError(c, err)
         return
     }
-    c.JSON(http.StatusOK, mapAnimal(raw))
+    item, err := mapAnimal(raw)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    if notModified(c, etag(item.Version), item.UpdatedAt) {
+        return
+    }
+    c.JSON(http.StatusOK, fields.Apply(item))
 }
 
 // ListAnimals godoc
 // @Summary List animals with filtering, sorting, pagination
 // @Tags animals
 // @Produce json
-// @Param species query string false "Species (dog, cat, bird, fish, reptile, other)"
+// @Param species query string false "Species ids or names, comma-separated (species!= excludes)"
 // @Param name query string false "Name contains"
 // @Param minAge query int false "Minimum age"
 // @Param maxAge query int false "Maximum age"
 // @Param adopted query bool false "Adopted status"
-// @Param sort query string false "Sort field (name, age, createdAt)"
-// @Param order query string false "asc or desc"
+// @Param createdAfter query string false "Created at or after (RFC3339 or YYYY-MM-DD)"
+// @Param createdBefore query string false "Created before (RFC3339 or YYYY-MM-DD)"
+// @Param updatedSince query string false "Updated at or after (RFC3339 or YYYY-MM-DD)"
+// @Param hasLocation query bool false "Has (or lacks) a location"
+// @Param hasImage query bool false "Has (or lacks) an image"
+// @Param hasOwner query bool false "Has (or lacks) an owner"
+// @Param filter query string false "Filter expression, e.g. age>=2 and (species=cat or species=dog) and not adopted"
+// @Param sort query string false "Sort keys, - for descending, e.g. -adopted,name,-age; also species and distance"
+// @Param order query string false "asc or desc, for a single unprefixed sort key"
+// @Param lat query number false "Latitude for sort=distance"
+// @Param lng query number false "Longitude for sort=distance"
 // @Param page query int false "Page number (1-based)"
 // @Param limit query int false "Page size"
+// @Param after query string false "Cursor: items after this position (from next)"
+// @Param before query string false "Cursor: items before this position (from prev)"
+// @Param fields query string false "Comma-separated fields to return, e.g. id,location"
+// @Param facets query string false "Comma-separated facets to count: species, adopted, ageBucket"
+// @Param count query string false "exact (default), estimated or none"
+// @Param ids query string false "Comma-separated ids: return just these animals, in this order, and the missing ids"
+// @Param If-None-Match header string false "Weak ETag of the page held by the client"
 // @Success 200 {object} map[string]interface{}
+// @Header 200 {string} Link "RFC 8288 first/next/prev links"
+// @Success 304 {string} string ""
 // @Router /animals [get]
 func (ac *AnimalController) ListAnimals(c *gin.Context) {
-    filter := bson.M{}
-
-    if species := strings.TrimSpace(c.Query("species")); species != "" {
-        // Match species if stored as string or as ObjectID
-        ors := []bson.M{{"species": species}}
-        if oid, err := primitive.ObjectIDFromHex(species); err == nil {
-            ors = append(ors, bson.M{"species": oid})
-        }
-        filter["$or"] = appendOr(filter["$or"], ors...)
-    }
-    if name := strings.TrimSpace(c.Query("name")); name != "" {
-        // support either name or animal_name
-        filter["$or"] = []bson.M{
-            {"name": bson.M{"$regex": name, "$options": "i"}},
-            {"animal_name": bson.M{"$regex": name, "$options": "i"}},
-        }
-    }
-    // Age may not exist; if birthdate exists in dataset, we can compute ages client-side.
-    // We'll not filter by age at DB-level when birthdate is used; keep age filter only if stored.
-    if minAgeStr := c.Query("minAge"); minAgeStr != "" {
-        if v, err := strconv.Atoi(minAgeStr); err == nil {
-            filter["age"] = bson.M{"$gte": v}
-        }
-    }
-    if maxAgeStr := c.Query("maxAge"); maxAgeStr != "" {
-        if v, err := strconv.Atoi(maxAgeStr); err == nil {
-            if existing, ok := filter["age"].(bson.M); ok {
-                existing["$lte"] = v
-                filter["age"] = existing
-            } else {
-                filter["age"] = bson.M{"$lte": v}
-            }
-        }
-    }
-    if adoptedStr := c.Query("adopted"); adoptedStr != "" {
-        if adoptedStr == "true" || adoptedStr == "false" {
-            filter["adopted"] = adoptedStr == "true"
-        }
-    }
-
-    // pagination
-    page := 1
-    limit := 10
-    if v, err := strconv.Atoi(c.DefaultQuery("page", "1")); err == nil && v > 0 {
-        page = v
-    }
-    if v, err := strconv.Atoi(c.DefaultQuery("limit", "10")); err == nil && v > 0 && v <= 100 {
-        limit = v
-    }
-    skip := int64((page - 1) * limit)
-
-    // sorting
-    sortField := c.DefaultQuery("sort", "createdAt")
-    // allow sorting by birthdate if present in dataset
-    allowed := map[string]bool{"name": true, "age": true, "createdAt": true, "birthdate": true, "animal_name": true}
-    if !allowed[sortField] {
-        sortField = "createdAt"
-    }
-    order := c.DefaultQuery("order", "desc")
-    sortDir := int32(-1)
-    if strings.ToLower(order) == "asc" {
-        sortDir = 1
-    }
-
-    // Build sort spec. If sorting by createdAt, add _id as a secondary sort to approximate creation time for docs missing createdAt.
-    sortSpec := bson.D{{Key: sortField, Value: sortDir}}
-    if sortField == "createdAt" {
-        sortSpec = bson.D{{Key: "createdAt", Value: sortDir}, {Key: "_id", Value: sortDir}}
-    }
-    findOpts := options.Find().SetSkip(skip).SetLimit(int64(limit)).SetSort(sortSpec)
+    animalList.List(c, ac.Collection)
+}
 
-    cur, err := ac.Collection.Find(db.Ctx, filter, findOpts)
-    if err != nil {
-        utils.ServerError(c, err)
-        return
-    }
-    defer cur.Close(db.Ctx)
+// BatchGetAnimals godoc
+// @Summary Get many animals by id
+// @Description Like GET /animals?ids=, for id lists too long for a URL.
+// @Tags animals
+// @Accept json
+// @Produce json
+// @Param ids body object true "{\"ids\": [\"...\"]}"
+// @Param fields query string false "Comma-separated fields to return"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /animals/batch-get [post]
+func (ac *AnimalController) BatchGetAnimals(c *gin.Context) {
+    animalList.BatchGetJSON(c, ac.Collection)
+}
 
-    var raws []bson.M
-    if err := cur.All(db.Ctx, &raws); err != nil {
-        utils.ServerError(c, err)
-        return
-    }
+// ExportAnimals godoc
+// @Summary Export all matching animals as CSV or NDJSON
+// @Description Accepts the same filters and sorting as GET /animals, without pagination. The response is streamed.
+// @Tags animals
+// @Produce text/csv,application/x-ndjson
+// @Param format query string false "csv or ndjson (default)"
+// @Success 200 {string} string ""
+// @Router /animals/export [get]
+func (ac *AnimalController) ExportAnimals(c *gin.Context) {
+    animalList.Export(c, ac.Collection, animalExporter)
+}
 
-    items := make([]models.Animal, 0, len(raws))
-    for _, r := range raws {
-        items = append(items, mapAnimal(r))
-    }
+// SuggestAnimals godoc
+// @Summary Autocomplete animal names
+// @Description Names starting with q come first; misspelled names (edit distance or trigram similarity) fill up the rest.
+// @Tags animals
+// @Produce json
+// @Param q query string true "Typed text"
+// @Param limit query int false "Maximum number of suggestions (default 10, max 50)"
+// @Param fuzzy query bool false "Include typo-tolerant matches (default true)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /animals/suggest [get]
+func (ac *AnimalController) SuggestAnimals(c *gin.Context) {
+    suggest(c, ac.Collection, suggester{
+        fields: []string{"name", "animal_name"},
+        item: func(raw bson.M) (suggestion, error) {
+            a, err := mapAnimal(raw)
+            return suggestion{ID: a.ID.Hex(), Name: a.Name, Species: a.Species}, err
+        },
+    })
+}
 
-    total, err := ac.Collection.CountDocuments(db.Ctx, filter)
-    if err != nil {
-        utils.ServerError(c, err)
-        return
-    }
+// animalList declares how animals are listed. species accepts ids or
+// species names.
+var animalList = &listSpec{
+    Fields: map[string]listField{
+        "name":      {Type: query.String, Paths: []string{"name", "animal_name"}},
+        "species":   {Type: query.Ref, Paths: []string{"species"}, Ref: &refTarget{"species", []string{"name", "species_name"}}},
+        "age":       {Type: query.Int, Paths: []string{"age"}},
+        "adopted":   {Type: query.Bool, Paths: []string{"adopted"}},
+        "owner":     {Type: query.String, Paths: []string{"owner"}},
+        "image":     {Type: query.String, Paths: []string{"image"}},
+        "createdAt": {Type: query.Time, Paths: []string{"createdAt"}},
+        "updatedAt": {Type: query.Time, Paths: []string{"updatedAt"}},
+        "adoptedAt": {Type: query.Time, Paths: []string{"adoptedAt"}},
+    },
+    Params: []listParam{
+        {Name: "species", Kind: paramRef, Field: "species"},
+        {Name: "name", Kind: paramContains, Field: "name"},
+        // Age may not exist; animals with only a birthdate are not matched by minAge/maxAge.
+        {Name: "minAge", Kind: paramMin, Field: "age"},
+        {Name: "maxAge", Kind: paramMax, Field: "age"},
+        {Name: "adopted", Kind: paramBool, Field: "adopted"},
+        {Name: "hasLocation", Kind: paramExists, Paths: []string{"location"}},
+        {Name: "hasImage", Kind: paramExists, Field: "image"},
+        {Name: "hasOwner", Kind: paramExists, Field: "owner"},
+    },
+    Sorts: map[string]sortKey{
+        "name":        {Path: "name", Text: true},
+        "animal_name": {Path: "animal_name", Text: true},
+        "owner":       {Path: "owner", Text: true},
+        "age":         {Path: "age"},
+        // allow sorting by birthdate if present in dataset
+        "birthdate": {Path: "birthdate"},
+        "adopted":   {Path: "adopted"},
+        "createdAt": {Path: "createdAt"},
+        "updatedAt": {Path: "updatedAt"},
+        "adoptedAt": {Path: "adoptedAt"},
+        // species name, stored as speciesSort
+        "species": refSortKey(db.SpeciesSort),
+        // from lat/lng
+        "distance": distanceKey("location"),
+    },
+    DefaultSort: "createdAt",
+    Paths:       animalFieldPaths,
+    Facets:      animalFacets,
+    Item: func(raw bson.Raw) (interface{}, error) {
+        return decodeAnimal(raw)
+    },
+}
 
-    c.JSON(http.StatusOK, gin.H{
-        "items": items,
-        "page":  page,
-        "limit": limit,
-        "total": total,
-    })
+// animalFacets are the facets= counts ListAnimals offers.
+var animalFacets = map[string]facet{
+    "species": {
+        stages: bson.A{
+            bson.M{"$group": bson.M{"_id": "$species", "n": bson.M{"$sum": 1}}},
+            bson.M{"$sort": bson.D{{Key: "n", Value: -1}, {Key: "_id", Value: 1}}},
+            bson.M{"$lookup": bson.M{
+                "from":     "species",
+                "let":      bson.M{"ref": "$_id"},
+                "pipeline": bson.A{refMatch("$$ref", "name", "species_name"), bson.M{"$limit": 1}},
+                "as":       "sp",
+            }},
+        },
+        result: func(rows []bson.M) interface{} {
+            out := make([]facetCount, 0, len(rows))
+            for _, r := range rows {
+                n, _ := toInt(r["n"])
+                fc := facetCount{Value: refString(r["_id"]), Count: n}
+                if sp, ok := r["sp"].(bson.A); ok && len(sp) > 0 {
+                    if doc, ok := sp[0].(bson.M); ok {
+                        if s, err := mapSpecies(doc); err == nil {
+                            fc.Value, fc.Name = s.ID.Hex(), s.Name
+                        }
+                    }
+                }
+                out = append(out, fc)
+            }
+            return out
+        },
+    },
+    "adopted": {
+        stages: bson.A{
+            bson.M{"$group": bson.M{"_id": bson.M{"$eq": bson.A{"$adopted", true}}, "n": bson.M{"$sum": 1}}},
+        },
+        result: func(rows []bson.M) interface{} {
+            counts := map[bool]int{}
+            for _, r := range countRows(rows) {
+                adopted, _ := r.ID.(bool)
+                counts[adopted] = r.N
+            }
+            return []facetCount{{Value: true, Count: counts[true]}, {Value: false, Count: counts[false]}}
+        },
+    },
+    "ageBucket": {
+        stages: bson.A{ageBucketStage(animalAgeExpr)},
+        result: func(rows []bson.M) interface{} {
+            return ageHistogram(countRows(rows))
+        },
+    },
 }
 
 // UpdateAnimal godoc
-// @Summary Update an animal by id
+// @Summary Replace an animal by id
+// @Description The body replaces the whole document: fields that are not provided are reset.
+// @Description createdAt is kept. Send X-Legacy-Put: true for the old partial-update behaviour.
 // @Tags animals
 // @Accept json
 // @Produce json
 // @Param id path string true "Animal ID"
+// @Param If-Match header string false "ETag of the version being updated"
+// @Param X-Legacy-Put header bool false "Treat PUT as a partial update"
 // @Param animal body models.Animal true "Animal"
 // @Success 200 {object} models.Animal
 // @Failure 400 {object} map[string]string
+// @Failure 412 {object} map[string]string
 // @Router /animals/{id} [put]
 func (ac *AnimalController) UpdateAnimal(c *gin.Context) {
+    if ac.LegacyPut || legacyPutRequested(c) {
+        ac.mergeAnimal(c)
+        return
+    }
+    oid, raw, ok := loadForWrite(c, ac.Collection)
+    if !ok {
+        return
+    }
+    var body animalInput
+    if err := c.ShouldBindJSON(&body); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    next := body.model()
+    if err := validate.Struct(next); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    prev, err := mapAnimal(raw)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    next.ID = oid
+    next.CreatedAt = prev.CreatedAt
+    next.UpdatedAt = time.Now().UTC()
+    next.Version = docVersion(raw) + 1
+    stampAdoption(&prev, &next, next.UpdatedAt)
+    if !saveReplace(c, ac.Collection, ac.Audit, "animals", oid, raw, next) {
+        return
+    }
+    refreshSortKeys(ac.Collection, db.SpeciesSort, oid)
+    setETag(c, next.Version)
+    c.JSON(http.StatusOK, next)
+}
+
+// mergeAnimal is the pre-replace PUT behaviour: only provided, non-empty fields are updated.
+func (ac *AnimalController) mergeAnimal(c *gin.Context) {
     id := c.Param("id")
     oid, err := primitive.ObjectIDFromHex(id)
     if err != nil {
         utils.BadRequest(c, errors.New("invalid id"))
         return
     }
-    type animalIn struct {
-        Name       string `json:"name"`
-        AnimalName string `json:"animal_name"`
-        Species    string `json:"species"`
-        Birthdate  string `json:"birthdate"`
-        Age        *int   `json:"age"`
-        Adopted    *bool  `json:"adopted"`
-        Image      string           `json:"image"`
-        Owner      string           `json:"owner"`
-        Location   *models.GeoPoint `json:"location"`
-    }
-    var body animalIn
+    var body animalInput
     if err := c.ShouldBindJSON(&body); err != nil {
         utils.BadRequest(c, err)
         return
@@ -322,29 +479,116 @@ func (ac *AnimalController) UpdateAnimal(c *gin.Context) {
         }
         set["location"] = gp
     }
+    if body.AdoptedAt != nil {
+        set["adoptedAt"] = body.AdoptedAt.UTC()
+    }
     set["updatedAt"] = time.Now().UTC()
 
-    update := bson.M{"$set": set}
+    // A pipeline update, so adoptedAt can depend on the stored adopted flag:
+    // it is stamped only when this update adopts the animal. Every value is a
+    // $literal, as strings starting with "$" would otherwise be field paths.
+    stage := bson.M{}
+    for k, v := range set {
+        stage[k] = bson.M{"$literal": v}
+    }
+    stage["version"] = bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, int64(1)}}
+    adopts := body.Adopted != nil && *body.Adopted && body.AdoptedAt == nil
+    if adopts {
+        stage["adoptedAt"] = bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$adopted", true}}, "$adoptedAt", set["updatedAt"]}}
+    }
+    update := bson.A{bson.M{"$set": stage}}
 
-    res := ac.Collection.FindOneAndUpdate(db.Ctx, bson.M{"_id": oid}, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
-    var updated models.Animal
-    if err := res.Decode(&updated); err != nil {
+    filter, ok := versionedFilter(c, oid)
+    if !ok {
+        return
+    }
+    // Fetch the previous version so the change can be audited; the new one is derived from it.
+    res := ac.Collection.FindOneAndUpdate(db.Ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.Before))
+    var before bson.M
+    if err := res.Decode(&before); err != nil {
         if errors.Is(err, mongo.ErrNoDocuments) {
-            utils.NotFound(c)
+            writeMiss(c, ac.Collection, oid)
             return
         }
         utils.ServerError(c, err)
         return
     }
-    c.JSON(http.StatusOK, updated)
+    if adopts && before["adopted"] != true {
+        set["adoptedAt"] = set["updatedAt"]
+    }
+    if _, ok := set["species"]; ok {
+        refreshSortKeys(ac.Collection, db.SpeciesSort, oid)
+    }
+    after, err := applySet(before, set)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    ac.Audit.Record(c, "animals", auditUpdate, oid, before, after)
+    respondAnimal(c, after)
+}
+
+// PatchAnimal godoc
+// @Summary Partially update an animal (RFC 7396 merge patch or RFC 6902 JSON patch)
+// @Description With application/merge-patch+json (or application/json) a null value clears the field.
+// @Description With application/json-patch+json the body is an array of operations.
+// @Tags animals
+// @Accept json
+// @Produce json
+// @Param id path string true "Animal ID"
+// @Param If-Match header string false "ETag of the version being updated"
+// @Param patch body object true "Patch document"
+// @Success 200 {object} models.Animal
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Router /animals/{id} [patch]
+func (ac *AnimalController) PatchAnimal(c *gin.Context) {
+    oid, raw, ok := loadForWrite(c, ac.Collection)
+    if !ok {
+        return
+    }
+    current, err := mapAnimal(raw)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    var next models.Animal
+    if err := decodePatch(c, current, &next); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    if next.Location != nil && next.Location.Type == "" {
+        next.Location.Type = "Point"
+    }
+    if err := validate.Struct(next); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    stampAdoption(&current, &next, time.Now().UTC())
+    set, unset, err := patchUpdate(current, next)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    after, ok := savePatch(c, ac.Collection, ac.Audit, "animals", oid, raw, set, unset)
+    if !ok {
+        return
+    }
+    if _, ok := set["species"]; ok {
+        refreshSortKeys(ac.Collection, db.SpeciesSort, oid)
+    }
+    respondAnimal(c, after)
 }
 
 // DeleteAnimal godoc
 // @Summary Delete an animal by id
 // @Tags animals
 // @Param id path string true "Animal ID"
+// @Param If-Match header string false "ETag of the version being deleted"
 // @Success 204 {string} string ""
 // @Failure 404 {object} map[string]string
+// @Failure 412 {object} map[string]string
 // @Router /animals/{id} [delete]
 func (ac *AnimalController) DeleteAnimal(c *gin.Context) {
     id := c.Param("id")
@@ -353,132 +597,56 @@ func (ac *AnimalController) DeleteAnimal(c *gin.Context) {
         utils.BadRequest(c, errors.New("invalid id"))
         return
     }
-    res, err := ac.Collection.DeleteOne(db.Ctx, bson.M{"_id": oid})
-    if err != nil {
-        utils.ServerError(c, err)
+    filter, ok := versionedFilter(c, oid)
+    if !ok {
         return
     }
-    if res.DeletedCount == 0 {
-        utils.NotFound(c)
+    var before bson.M
+    if err := ac.Collection.FindOneAndDelete(db.Ctx, filter).Decode(&before); err != nil {
+        if errors.Is(err, mongo.ErrNoDocuments) {
+            writeMiss(c, ac.Collection, oid)
+            return
+        }
+        utils.ServerError(c, err)
         return
     }
+    ac.Audit.Record(c, "animals", auditDelete, oid, before, nil)
     c.Status(http.StatusNoContent)
 }
 
+// respondAnimal answers a write with the animal after it.
+func respondAnimal(c *gin.Context, after bson.M) {
+    a, err := mapAnimal(after)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    setETag(c, a.Version)
+    c.JSON(http.StatusOK, a)
+}
+
 // mapAnimal converts a raw bson document (which may come from a different dataset schema)
 // to our models.Animal format. It handles aliases like animal_name -> name and computes age from birthdate if needed.
-func mapAnimal(raw bson.M) models.Animal {
-    var out models.Animal
-    if id, ok := raw["_id"].(primitive.ObjectID); ok {
-        out.ID = id
-    }
-    // name from either name or animal_name
-    if n, ok := raw["name"].(string); ok && n != "" {
-        out.Name = n
-    } else if an, ok := raw["animal_name"].(string); ok {
-        out.Name = an
-    }
-    if s, ok := raw["species"].(string); ok {
-        out.Species = s
-    } else if soid, ok := raw["species"].(primitive.ObjectID); ok {
-        out.Species = soid.Hex()
-    }
-    // Prefer stored age; otherwise derive from birthdate (YYYY-MM-DD)
-    if a, ok := raw["age"].(int32); ok {
-        out.Age = int(a)
-    } else if a64, ok := raw["age"].(int64); ok {
-        out.Age = int(a64)
-    } else if aF, ok := raw["age"].(float64); ok {
-        out.Age = int(aF)
-    } else if bd, ok := raw["birthdate"].(string); ok {
-        out.Age = utils.AgeFromBirthdate(bd)
-        if out.Age < 0 {
-            out.Age = 0
-        }
-    } else if bdt, ok := raw["birthdate"].(time.Time); ok {
-        out.Age = utils.AgeFromTime(bdt)
-        if out.Age < 0 {
-            out.Age = 0
-        }
-    } else if bddt, ok := raw["birthdate"].(primitive.DateTime); ok {
-        out.Age = utils.AgeFromTime(bddt.Time())
-        if out.Age < 0 {
-            out.Age = 0
-        }
-    }
-    if ad, ok := raw["adopted"].(bool); ok {
-        out.Adopted = ad
-    }
-    if img, ok := raw["image"].(string); ok {
-        out.Image = img
-    }
-    if owner, ok := raw["owner"].(string); ok {
-        out.Owner = owner
-    }
-    // location as GeoJSON
-    if loc, ok := raw["location"].(bson.M); ok {
-        gp := models.GeoPoint{}
-        if t, ok := loc["type"].(string); ok {
-            gp.Type = t
-        }
-        // coordinates might be []interface{} or []float64
-        if coords, ok := loc["coordinates"].(primitive.A); ok {
-            gp.Coordinates = make([]float64, 0, len(coords))
-            for _, v := range coords {
-                switch num := v.(type) {
-                case float64:
-                    gp.Coordinates = append(gp.Coordinates, num)
-                case float32:
-                    gp.Coordinates = append(gp.Coordinates, float64(num))
-                case int32:
-                    gp.Coordinates = append(gp.Coordinates, float64(num))
-                case int64:
-                    gp.Coordinates = append(gp.Coordinates, float64(num))
-                case int:
-                    gp.Coordinates = append(gp.Coordinates, float64(num))
-                }
-            }
-        } else if coords2, ok := loc["coordinates"].([]interface{}); ok {
-            gp.Coordinates = make([]float64, 0, len(coords2))
-            for _, v := range coords2 {
-                switch num := v.(type) {
-                case float64:
-                    gp.Coordinates = append(gp.Coordinates, num)
-                case float32:
-                    gp.Coordinates = append(gp.Coordinates, float64(num))
-                case int32:
-                    gp.Coordinates = append(gp.Coordinates, float64(num))
-                case int64:
-                    gp.Coordinates = append(gp.Coordinates, float64(num))
-                case int:
-                    gp.Coordinates = append(gp.Coordinates, float64(num))
-                }
-            }
-        } else if coordsF64, ok := loc["coordinates"].([]float64); ok {
-            gp.Coordinates = coordsF64
-        }
-        out.Location = &gp
-    }
-    if ct, ok := raw["createdAt"].(time.Time); ok { out.CreatedAt = ct }
-    if ut, ok := raw["updatedAt"].(time.Time); ok { out.UpdatedAt = ut }
-    // Fallbacks for legacy docs: derive createdAt from ObjectID timestamp; updatedAt defaults to createdAt
-    if out.CreatedAt.IsZero() && out.ID != primitive.NilObjectID {
-        out.CreatedAt = out.ID.Timestamp()
-    }
-    if out.UpdatedAt.IsZero() {
-        out.UpdatedAt = out.CreatedAt
+func mapAnimal(raw bson.M) (models.Animal, error) {
+    b, err := bson.Marshal(raw)
+    if err != nil {
+        return models.Animal{}, err
     }
-    return out
+    return decodeAnimal(b)
 }
 
-// appendOr appends conditions to an existing $or which can be nil, a slice, or missing.
-func appendOr(existing any, conds ...bson.M) []bson.M {
-    var or []bson.M
-    if existing != nil {
-        if arr, ok := existing.([]bson.M); ok {
-            or = append(or, arr...)
-        }
-    }
-    or = append(or, conds...)
-    return or
+// decodeAnimal reads a stored animal; see models.Animal.UnmarshalBSON for
+// the legacy shapes it accepts. List pages and exports decode with it
+// directly, skipping the bson.M round trip.
+func decodeAnimal(raw bson.Raw) (models.Animal, error) {
+    var out models.Animal
+    err := out.UnmarshalBSON(raw)
+    return out, err
+}
+
+// skipUndecodable logs a stored document that a list, export or search
+// leaves out because it could not be decoded. Only a corrupt document gets
+// here; one bad document should not fail the whole page.
+func skipUndecodable(collection string, id interface{}, err error) {
+    log.Printf("%s: skipping undecodable document %v: %v", collection, id, err)
 }
@@ -0,0 +1,265 @@
+package controllers
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+    "go-api/pkg/models"
+    "go-api/pkg/utils"
+)
+
+// legacyMapAnimal is mapAnimal as it was before the UnmarshalBSON decoders:
+// a type switch over a bson.M. It is kept as the baseline of
+// BenchmarkDecodeAnimal.
+func legacyMapAnimal(raw bson.M) models.Animal {
+    var out models.Animal
+    if id, ok := raw["_id"].(primitive.ObjectID); ok {
+        out.ID = id
+    }
+    if n, ok := raw["name"].(string); ok && n != "" {
+        out.Name = n
+    } else if an, ok := raw["animal_name"].(string); ok {
+        out.Name = an
+    }
+    if s, ok := raw["species"].(string); ok {
+        out.Species = s
+    } else if soid, ok := raw["species"].(primitive.ObjectID); ok {
+        out.Species = soid.Hex()
+    }
+    if a, ok := raw["age"].(int32); ok {
+        out.Age = int(a)
+    } else if a64, ok := raw["age"].(int64); ok {
+        out.Age = int(a64)
+    } else if aF, ok := raw["age"].(float64); ok {
+        out.Age = int(aF)
+    } else if bd, ok := raw["birthdate"].(string); ok {
+        out.Age = utils.AgeFromBirthdate(bd)
+        if out.Age < 0 {
+            out.Age = 0
+        }
+    } else if bddt, ok := raw["birthdate"].(primitive.DateTime); ok {
+        out.Age = utils.AgeFromTime(bddt.Time())
+        if out.Age < 0 {
+            out.Age = 0
+        }
+    }
+    if ad, ok := raw["adopted"].(bool); ok {
+        out.Adopted = ad
+    }
+    if img, ok := raw["image"].(string); ok {
+        out.Image = img
+    }
+    if owner, ok := raw["owner"].(string); ok {
+        out.Owner = owner
+    }
+    if loc, ok := raw["location"].(bson.M); ok {
+        gp := models.GeoPoint{}
+        if t, ok := loc["type"].(string); ok {
+            gp.Type = t
+        }
+        if coords, ok := loc["coordinates"].(primitive.A); ok {
+            gp.Coordinates = make([]float64, 0, len(coords))
+            for _, v := range coords {
+                switch num := v.(type) {
+                case float64:
+                    gp.Coordinates = append(gp.Coordinates, num)
+                case int32:
+                    gp.Coordinates = append(gp.Coordinates, float64(num))
+                case int64:
+                    gp.Coordinates = append(gp.Coordinates, float64(num))
+                }
+            }
+        }
+        out.Location = &gp
+    }
+    if ct, ok := raw["createdAt"].(time.Time); ok {
+        out.CreatedAt = ct
+    }
+    if ut, ok := raw["updatedAt"].(time.Time); ok {
+        out.UpdatedAt = ut
+    }
+    switch at := raw["adoptedAt"].(type) {
+    case primitive.DateTime:
+        t := at.Time().UTC()
+        out.AdoptedAt = &t
+    case time.Time:
+        out.AdoptedAt = &at
+    }
+    if out.CreatedAt.IsZero() && out.ID != primitive.NilObjectID {
+        out.CreatedAt = out.ID.Timestamp()
+    }
+    if out.UpdatedAt.IsZero() {
+        out.UpdatedAt = out.CreatedAt
+    }
+    out.Version = docVersion(raw)
+    return out
+}
+
+// animalPage is a page of n stored animals mixing current and legacy shapes.
+func animalPage(n int) []bson.Raw {
+    now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+    page := make([]bson.Raw, n)
+    for i := range page {
+        doc := bson.M{
+            "_id":       primitive.NewObjectIDFromTimestamp(now.Add(-time.Duration(i) * time.Hour)),
+            "species":   primitive.NewObjectID().Hex(),
+            "adopted":   i%3 == 0,
+            "image":     fmt.Sprintf("animal-%d.jpg", i),
+            "location":  bson.M{"type": "Point", "coordinates": bson.A{24.9 + float64(i)/100, 60.1}},
+            "createdAt": now,
+            "updatedAt": now,
+            "version":   int64(1 + i%4),
+        }
+        switch i % 4 {
+        case 0:
+            doc["name"], doc["age"] = fmt.Sprintf("Misu %d", i), int32(i%15)
+        case 1:
+            doc["animal_name"], doc["age"] = fmt.Sprintf("Rekku %d", i), float64(i%15)
+        case 2:
+            doc["name"], doc["birthdate"] = fmt.Sprintf("Nalle %d", i), "2019-03-04"
+        case 3:
+            doc["name"], doc["species"] = fmt.Sprintf("Pörrö %d", i), primitive.NewObjectID()
+            doc["owner"], doc["adoptedAt"] = "Anna", now
+        }
+        b, err := bson.Marshal(doc)
+        if err != nil {
+            panic(err)
+        }
+        page[i] = b
+    }
+    return page
+}
+
+// BenchmarkDecodeAnimal decodes a 100-animal page as list pages and exports
+// do, against the former decoding into a bson.M and mapping it.
+func BenchmarkDecodeAnimal(b *testing.B) {
+    page := animalPage(100)
+    b.Run("bson.M", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            for _, raw := range page {
+                var m bson.M
+                if err := bson.Unmarshal(raw, &m); err != nil {
+                    b.Fatal(err)
+                }
+                legacyMapAnimal(m)
+            }
+        }
+    })
+    b.Run("UnmarshalBSON", func(b *testing.B) {
+        b.ReportAllocs()
+        for i := 0; i < b.N; i++ {
+            for _, raw := range page {
+                decodeAnimal(raw)
+            }
+        }
+    })
+}
+
+// The timestamps are left out: the legacy mapping expected time.Time, which
+// a bson.M never holds, and fell back to the ObjectID time.
+func TestDecodeAnimalMatchesLegacyMapping(t *testing.T) {
+    for i, raw := range animalPage(8) {
+        var m bson.M
+        if err := bson.Unmarshal(raw, &m); err != nil {
+            t.Fatal(err)
+        }
+        got, err := decodeAnimal(raw)
+        if err != nil {
+            t.Fatal(err)
+        }
+        want := legacyMapAnimal(m)
+        if got.ID != want.ID || got.Name != want.Name || got.Species != want.Species || got.Age != want.Age ||
+            got.Adopted != want.Adopted || got.Owner != want.Owner || got.Version != want.Version ||
+            !sameTime(got.AdoptedAt, want.AdoptedAt) {
+            t.Errorf("document %d:\n got %+v\nwant %+v", i, got, want)
+        }
+    }
+}
+
+func TestDecodeAnimalReportsCorruptDocuments(t *testing.T) {
+    // a document claiming more bytes than it has
+    if _, err := decodeAnimal(bson.Raw{0x20, 0, 0, 0, 0x02, 'n', 0}); err == nil {
+        t.Error("decodeAnimal accepted a truncated document")
+    }
+    if _, err := mapAnimal(bson.M{"name": make(chan int)}); err == nil {
+        t.Error("mapAnimal accepted a document it cannot marshal")
+    }
+}
+
+// The legacy PUT stamps adoptedAt in the same update that adopts the animal,
+// deciding from the stored flag, so a concurrent adoption cannot be restamped.
+func TestMergeAnimalStampsAdoptionInOneUpdate(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    id := primitive.NewObjectID()
+
+    mt.Run("adopts", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: storedAnimal(id)}))
+        ac := &AnimalController{Collection: mt.Coll, LegacyPut: true}
+        w := httptest.NewRecorder()
+        c, _ := gin.CreateTestContext(w)
+        c.Params = gin.Params{{Key: "id", Value: id.Hex()}}
+        c.Request = httptest.NewRequest("PUT", "/animals/"+id.Hex(), strings.NewReader(`{"adopted":true,"owner":"$adopted"}`))
+        c.Request.Header.Set("Content-Type", "application/json")
+        ac.UpdateAnimal(c)
+        if w.Code != http.StatusOK {
+            t.Fatalf("status %d: %s", w.Code, w.Body)
+        }
+        var got models.Animal
+        if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+            t.Fatal(err)
+        }
+        if !got.Adopted || got.AdoptedAt == nil || got.Owner != "$adopted" || got.Version != 2 {
+            t.Errorf("response = %+v", got)
+        }
+        if n := len(writeCommands(mt, "update")); n != 0 {
+            t.Errorf("%d separate update commands, want none", n)
+        }
+        cmds := writeCommands(mt, "findAndModify")
+        if len(cmds) != 1 {
+            t.Fatalf("%d findAndModify commands, want 1", len(cmds))
+        }
+        stages, ok := cmds[0].Lookup("update").ArrayOK()
+        if !ok {
+            t.Fatalf("update is not a pipeline: %v", cmds[0].Lookup("update"))
+        }
+        set := stages.Index(0).Value().Document().Lookup("$set").Document()
+        if _, err := set.LookupErr("adoptedAt", "$cond"); err != nil {
+            t.Errorf("adoptedAt is not conditional on the stored flag: %v", set)
+        }
+        if owner := set.Lookup("owner", "$literal").StringValue(); owner != "$adopted" {
+            t.Errorf("owner = %v, want the literal text", set.Lookup("owner"))
+        }
+    })
+
+    mt.Run("already adopted", func(mt *mtest.T) {
+        adoptedAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+        stored := append(storedAnimal(id), bson.E{Key: "adoptedAt", Value: adoptedAt})
+        stored[4].Value = true
+        mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: stored}))
+        ac := &AnimalController{Collection: mt.Coll, LegacyPut: true}
+        w := httptest.NewRecorder()
+        c, _ := gin.CreateTestContext(w)
+        c.Params = gin.Params{{Key: "id", Value: id.Hex()}}
+        c.Request = httptest.NewRequest("PUT", "/animals/"+id.Hex(), strings.NewReader(`{"adopted":true}`))
+        c.Request.Header.Set("Content-Type", "application/json")
+        ac.UpdateAnimal(c)
+        var got models.Animal
+        if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+            t.Fatal(err)
+        }
+        if got.AdoptedAt == nil || !got.AdoptedAt.Equal(adoptedAt) {
+            t.Errorf("adoptedAt = %v, want the stored %v", got.AdoptedAt, adoptedAt)
+        }
+    })
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+    "log"
+    "reflect"
+    "sort"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+    "go-api/pkg/middleware"
+    "go-api/pkg/models"
+)
+
+const (
+    auditCreate = "create"
+    auditUpdate = "update"
+    auditDelete = "delete"
+)
+
+// AuditLog records mutations of the core collections into the audit collection.
+type AuditLog struct {
+    Collection *mongo.Collection
+}
+
+func NewAuditLog(database *mongo.Database) *AuditLog {
+    // Decode nested before/after values as maps so they render as JSON objects.
+    opts := options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true})
+    return &AuditLog{Collection: database.Collection("audit", opts)}
+}
+
+// Record stores a field-level diff of a mutation. before is nil for creates and
+// after is nil for deletes. Failures are logged rather than surfaced, since the
+// mutation itself has already been applied.
+func (al *AuditLog) Record(c *gin.Context, collection, action string, id primitive.ObjectID, before, after bson.M) {
+    if al == nil {
+        return
+    }
+    al.RecordAs(middleware.GetActor(c), middleware.GetRequestID(c), collection, action, id, before, after)
+}
+
+// RecordAs is Record for mutations made outside a request, such as the
+// import-dataset command.
+func (al *AuditLog) RecordAs(actor, requestID, collection, action string, id primitive.ObjectID, before, after bson.M) {
+    if al == nil {
+        return
+    }
+    changes := diffDocs(before, after)
+    if action == auditUpdate && len(changes) == 0 {
+        return
+    }
+    entry := models.AuditEntry{
+        Collection: collection,
+        DocumentID: id,
+        Action:     action,
+        Actor:      actor,
+        RequestID:  requestID,
+        Changes:    changes,
+        Timestamp:  time.Now().UTC(),
+    }
+    if _, err := al.Collection.InsertOne(db.Ctx, entry); err != nil {
+        log.Printf("audit: failed to record %s on %s/%s: %v", action, collection, id.Hex(), err)
+    }
+}
+
+// diffDocs compares two documents field by field. Bookkeeping fields and
+// stored sort keys are skipped.
+func diffDocs(before, after bson.M) []models.FieldChange {
+    keys := map[string]bool{}
+    for k := range before {
+        keys[k] = true
+    }
+    for k := range after {
+        keys[k] = true
+    }
+    delete(keys, "_id")
+    delete(keys, "updatedAt")
+    delete(keys, "version")
+    // derived sort keys follow the referenced documents
+    delete(keys, db.SpeciesSort.Key)
+    delete(keys, db.CategorySort.Key)
+
+    fields := make([]string, 0, len(keys))
+    for k := range keys {
+        fields = append(fields, k)
+    }
+    sort.Strings(fields)
+
+    changes := []models.FieldChange{}
+    for _, f := range fields {
+        b, a := before[f], after[f]
+        if reflect.DeepEqual(b, a) {
+            continue
+        }
+        changes = append(changes, models.FieldChange{Field: f, Before: b, After: a})
+    }
+    return changes
+}
+
+// toDoc normalizes any bson-marshalable value into a bson.M so it compares
+// equal to documents decoded from the database.
+func toDoc(v interface{}) (bson.M, error) {
+    b, err := bson.Marshal(v)
+    if err != nil {
+        return nil, err
+    }
+    var out bson.M
+    if err := bson.Unmarshal(b, &out); err != nil {
+        return nil, err
+    }
+    return out, nil
+}
+
+// applySet returns a normalized copy of doc with the fields of a $set applied
+// and the version incremented, mirroring the update sent to the database.
+func applySet(doc bson.M, set bson.M) (bson.M, error) {
+    return applyUpdate(doc, set, nil)
+}
+
+// applyUpdate is applySet that also removes the fields of an $unset.
+func applyUpdate(doc bson.M, set, unset bson.M) (bson.M, error) {
+    merged := bson.M{}
+    for k, v := range doc {
+        merged[k] = v
+    }
+    for k, v := range set {
+        merged[k] = v
+    }
+    for k := range unset {
+        delete(merged, k)
+    }
+    merged["version"] = docVersion(doc) + 1
+    return toDoc(merged)
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+    "go-api/pkg/models"
+    "go-api/pkg/query"
+    "go-api/pkg/utils"
+)
+
+type AuditController struct {
+    Log *AuditLog
+}
+
+func NewAuditController(client *mongo.Client, dbName string) *AuditController {
+    return &AuditController{Log: NewAuditLog(client.Database(dbName))}
+}
+
+// History godoc
+// @Summary Audit history of a document, newest first
+// @Tags audit
+// @Produce json
+// @Param id path string true "Document ID"
+// @Param page query int false "Page number (1-based)"
+// @Param limit query int false "Page size"
+// @Success 200 {object} map[string]interface{}
+// @Router /animals/{id}/history [get]
+func (ac *AuditController) History(collection string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        oid, err := primitive.ObjectIDFromHex(c.Param("id"))
+        if err != nil {
+            utils.BadRequest(c, errors.New("invalid id"))
+            return
+        }
+        ac.list(c, bson.M{"collection": collection, "documentId": oid})
+    }
+}
+
+// ListAudit godoc
+// @Summary List audit entries (admin)
+// @Tags audit
+// @Produce json
+// @Param collection query string false "animals, categories or species"
+// @Param documentId query string false "Document ID"
+// @Param action query string false "create, update or delete"
+// @Param actor query string false "Actor"
+// @Param requestId query string false "Request ID"
+// @Param field query string false "Only entries that changed this field"
+// @Param from query string false "Not before (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Before (RFC3339 or YYYY-MM-DD)"
+// @Param page query int false "Page number (1-based)"
+// @Param limit query int false "Page size"
+// @Success 200 {object} map[string]interface{}
+// @Router /audit [get]
+func (ac *AuditController) ListAudit(c *gin.Context) {
+    filter := bson.M{}
+    for _, key := range []string{"collection", "action", "actor", "requestId"} {
+        if v := strings.TrimSpace(c.Query(key)); v != "" {
+            filter[key] = v
+        }
+    }
+    if v := strings.TrimSpace(c.Query("documentId")); v != "" {
+        oid, err := primitive.ObjectIDFromHex(v)
+        if err != nil {
+            utils.BadRequest(c, errors.New("invalid documentId"))
+            return
+        }
+        filter["documentId"] = oid
+    }
+    if v := strings.TrimSpace(c.Query("field")); v != "" {
+        filter["changes.field"] = v
+    }
+    ts := bson.M{}
+    if v := c.Query("from"); v != "" {
+        t, err := query.ParseTime(v)
+        if err != nil {
+            utils.BadRequest(c, errors.New("invalid from"))
+            return
+        }
+        ts["$gte"] = t
+    }
+    if v := c.Query("to"); v != "" {
+        t, err := query.ParseTime(v)
+        if err != nil {
+            utils.BadRequest(c, errors.New("invalid to"))
+            return
+        }
+        ts["$lt"] = t
+    }
+    if len(ts) > 0 {
+        filter["timestamp"] = ts
+    }
+    ac.list(c, filter)
+}
+
+func (ac *AuditController) list(c *gin.Context, filter bson.M) {
+    page := 1
+    limit := 20
+    if v, err := strconv.Atoi(c.DefaultQuery("page", "1")); err == nil && v > 0 {
+        page = v
+    }
+    if v, err := strconv.Atoi(c.DefaultQuery("limit", "20")); err == nil && v > 0 && v <= 100 {
+        limit = v
+    }
+    skip := int64((page - 1) * limit)
+    opts := options.Find().SetSkip(skip).SetLimit(int64(limit)).SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}})
+
+    cur, err := ac.Log.Collection.Find(db.Ctx, filter, opts)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    defer cur.Close(db.Ctx)
+    items := make([]models.AuditEntry, 0, limit)
+    if err := cur.All(db.Ctx, &items); err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    total, err := ac.Log.Collection.CountDocuments(db.Ctx, filter)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"items": items, "page": page, "limit": limit, "total": total})
+}
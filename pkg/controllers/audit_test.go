@@ -0,0 +1,121 @@
+package controllers
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+    "go-api/pkg/middleware"
+    "go-api/pkg/models"
+)
+
+func TestDiffDocs(t *testing.T) {
+    id := primitive.NewObjectID()
+    t1 := primitive.NewDateTimeFromTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    t2 := primitive.NewDateTimeFromTime(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+    stored := bson.M{
+        "_id": id, "name": "Misu", "age": int32(2), "updatedAt": t1, "version": int64(1),
+        "speciesSort": "cat", "categorySort": "mammals",
+    }
+    tests := []struct {
+        name          string
+        before, after bson.M
+        want          []models.FieldChange
+    }{
+        {"create", nil, stored, []models.FieldChange{
+            {Field: "age", After: int32(2)},
+            {Field: "name", After: "Misu"},
+        }},
+        {"delete", stored, nil, []models.FieldChange{
+            {Field: "age", Before: int32(2)},
+            {Field: "name", Before: "Misu"},
+        }},
+        {"bookkeeping and sort keys only", stored, bson.M{
+            "_id": id, "name": "Misu", "age": int32(2), "updatedAt": t2, "version": int64(2),
+            "speciesSort": "dog", "categorySort": "birds",
+        }, []models.FieldChange{}},
+        {"changed, added and removed fields", stored, bson.M{
+            "_id": id, "name": "Mirri", "owner": "Anna", "updatedAt": t2, "version": int64(2),
+        }, []models.FieldChange{
+            {Field: "age", Before: int32(2)},
+            {Field: "name", Before: "Misu", After: "Mirri"},
+            {Field: "owner", After: "Anna"},
+        }},
+        {"nested values compare deeply", bson.M{"location": bson.M{"coordinates": bson.A{24.9, 60.1}}},
+            bson.M{"location": bson.M{"coordinates": bson.A{24.9, 60.1}}}, []models.FieldChange{}},
+    }
+    for _, tt := range tests {
+        if got := diffDocs(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestAuditActor(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    tests := []struct {
+        name  string
+        setup func(c *gin.Context)
+        actor string
+    }{
+        {"auth identity wins", func(c *gin.Context) {
+            c.Set(middleware.ActorKey, "alice")
+            c.Request.Header.Set(middleware.ActorHeader, "mallory")
+        }, "alice"},
+        {"header", func(c *gin.Context) { c.Request.Header.Set(middleware.ActorHeader, "  bob ") }, "bob"},
+        {"anonymous", func(c *gin.Context) {}, "anonymous"},
+    }
+    for _, tt := range tests {
+        mt.Run(tt.name, func(mt *mtest.T) {
+            mt.AddMockResponses(mtest.CreateSuccessResponse())
+            c, _ := gin.CreateTestContext(httptest.NewRecorder())
+            c.Request = httptest.NewRequest("POST", "/animals", nil)
+            c.Set(middleware.RequestIDKey, "req-1")
+            tt.setup(c)
+            al := &AuditLog{Collection: mt.Coll}
+            al.Record(c, "animals", auditCreate, primitive.NewObjectID(), nil, bson.M{"name": "Misu"})
+            inserts := writeCommands(mt, "insert")
+            if len(inserts) != 1 {
+                t.Fatalf("%d inserts, want 1", len(inserts))
+            }
+            entry := inserts[0].Lookup("documents").Array().Index(0).Value().Document()
+            if got := entry.Lookup("actor").StringValue(); got != tt.actor {
+                t.Errorf("actor = %q, want %q", got, tt.actor)
+            }
+            if got := entry.Lookup("requestId").StringValue(); got != "req-1" {
+                t.Errorf("requestId = %q, want req-1", got)
+            }
+        })
+    }
+
+    mt.Run("no-op update is not recorded", func(mt *mtest.T) {
+        c, _ := gin.CreateTestContext(httptest.NewRecorder())
+        c.Request = httptest.NewRequest("PUT", "/animals", nil)
+        al := &AuditLog{Collection: mt.Coll}
+        doc := bson.M{"name": "Misu", "version": int64(1)}
+        al.Record(c, "animals", auditUpdate, primitive.NewObjectID(), doc, bson.M{"name": "Misu", "version": int64(2)})
+        if n := len(writeCommands(mt, "insert")); n != 0 {
+            t.Errorf("%d inserts, want none", n)
+        }
+    })
+}
+
+func TestListAuditRejectsBadTimes(t *testing.T) {
+    ac := &AuditController{}
+    for _, target := range []string{"/audit?from=yesterday", "/audit?to=2024-13-01", "/audit?from=2024-01-02T10:00"} {
+        w := httptest.NewRecorder()
+        c, _ := gin.CreateTestContext(w)
+        c.Request = httptest.NewRequest("GET", target, nil)
+        ac.ListAudit(c)
+        if w.Code != http.StatusBadRequest {
+            t.Errorf("%s: status %d, want 400", target, w.Code)
+        }
+    }
+}
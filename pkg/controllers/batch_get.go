@@ -0,0 +1,133 @@
+package controllers
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+    "go-api/pkg/utils"
+)
+
+// parseBatchIDs converts the ids of a batch get, dropping repeats. Every
+// invalid id is reported in the error.
+func parseBatchIDs(hexes []string) ([]primitive.ObjectID, error) {
+    if len(hexes) == 0 {
+        return nil, errors.New("no ids")
+    }
+    if len(hexes) > maxBulkItems {
+        return nil, fmt.Errorf("at most %d ids per request", maxBulkItems)
+    }
+    ids := make([]primitive.ObjectID, 0, len(hexes))
+    seen := map[primitive.ObjectID]bool{}
+    var bad []string
+    for _, h := range hexes {
+        oid, err := primitive.ObjectIDFromHex(strings.TrimSpace(h))
+        if err != nil {
+            bad = append(bad, strconv.Quote(h))
+            continue
+        }
+        if !seen[oid] {
+            seen[oid] = true
+            ids = append(ids, oid)
+        }
+    }
+    if len(bad) > 0 {
+        return nil, errors.New("invalid id " + strings.Join(bad, ", "))
+    }
+    return ids, nil
+}
+
+// BatchGetQuery serves ids=a,b,c on a list endpoint.
+func (s *listSpec) BatchGetQuery(c *gin.Context, coll *mongo.Collection) {
+    ids, err := parseBatchIDs(splitList(c.Query("ids")))
+    if err != nil {
+        params(c).reject("ids", err.Error())
+    }
+    fields := parseFields(c, s.Paths)
+    if invalidParams(c) {
+        return
+    }
+    body, err := s.batchGet(coll, ids, fields)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    respondList(c, body)
+}
+
+// BatchGetJSON serves a batch get whose ids come in a {"ids": [...]} body,
+// for lists too long for a URL.
+func (s *listSpec) BatchGetJSON(c *gin.Context, coll *mongo.Collection) {
+    var in struct {
+        IDs []string `json:"ids"`
+    }
+    if err := c.ShouldBindJSON(&in); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    ids, err := parseBatchIDs(in.IDs)
+    if err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    fields := parseFields(c, s.Paths)
+    if invalidParams(c) {
+        return
+    }
+    body, err := s.batchGet(coll, ids, fields)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, body)
+}
+
+// batchGet fetches ids in one query. Items come back in the order of ids;
+// the ids without a document are listed under missing.
+func (s *listSpec) batchGet(coll *mongo.Collection, ids []primitive.ObjectID, fields *fieldSet) (gin.H, error) {
+    opts := options.Find()
+    if proj := fields.Projection(); proj != nil {
+        opts.SetProjection(proj)
+    }
+    cur, err := coll.Find(db.Ctx, bson.M{"_id": bson.M{"$in": ids}}, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cur.Close(db.Ctx)
+    found := make(map[primitive.ObjectID]interface{}, len(ids))
+    for cur.Next(db.Ctx) {
+        oid, ok := cur.Current.Lookup("_id").ObjectIDOK()
+        if !ok {
+            continue
+        }
+        item, err := s.Item(cur.Current)
+        if err != nil {
+            skipUndecodable(coll.Name(), oid, err)
+            continue
+        }
+        found[oid] = fields.Apply(item)
+    }
+    if err := cur.Err(); err != nil {
+        return nil, err
+    }
+
+    items := make([]interface{}, 0, len(found))
+    missing := []string{}
+    for _, oid := range ids {
+        if item, ok := found[oid]; ok {
+            items = append(items, item)
+        } else {
+            missing = append(missing, oid.Hex())
+        }
+    }
+    return gin.H{"items": items, "missing": missing}, nil
+}
@@ -0,0 +1,293 @@
+package controllers
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+)
+
+// maxBulkItems caps the number of items accepted by one bulk request.
+const maxBulkItems = 1000
+
+// bulkResult is the outcome of one item of a bulk request. Status uses HTTP
+// status codes; 424 marks items skipped after an earlier failure in ordered mode.
+type bulkResult struct {
+    Index  int    `json:"index"`
+    Row    int    `json:"row,omitempty"` // line number, for file imports
+    Status int    `json:"status"`
+    ID     string `json:"id,omitempty"`
+    Error  string `json:"error,omitempty"`
+}
+
+func (r bulkResult) ok() bool {
+    return r.Status < 300
+}
+
+// bulkBatch tracks per-item results of a bulk request together with the
+// write models queued for the items that passed validation.
+type bulkBatch struct {
+    Ordered bool
+    Results []bulkResult
+    Models  []mongo.WriteModel
+    // owners maps each write model back to the index of its item.
+    owners []int
+    // firstFailed is the index of the first failed item, or len(Results).
+    firstFailed int
+}
+
+func newBulkBatch(n int, ordered bool) *bulkBatch {
+    b := &bulkBatch{Ordered: ordered, Results: make([]bulkResult, n), firstFailed: n}
+    for i := range b.Results {
+        b.Results[i].Index = i
+    }
+    return b
+}
+
+// Stopped reports whether later items must be skipped (ordered mode after a failure).
+func (b *bulkBatch) Stopped() bool {
+    return b.Ordered && b.firstFailed < len(b.Results)
+}
+
+// Fail marks item i as failed with the given status.
+func (b *bulkBatch) Fail(i, status int, err error) {
+    b.Results[i].Status = status
+    b.Results[i].Error = err.Error()
+    if i < b.firstFailed {
+        b.firstFailed = i
+    }
+}
+
+// Done marks item i as completed without a write.
+func (b *bulkBatch) Done(i, status int, id string) {
+    b.Results[i].Status = status
+    b.Results[i].ID = id
+}
+
+// Queue records the write for item i; it is marked with status once the write succeeds.
+func (b *bulkBatch) Queue(i, status int, id string, m mongo.WriteModel) {
+    b.Results[i].Status = status
+    b.Results[i].ID = id
+    b.Models = append(b.Models, m)
+    b.owners = append(b.owners, i)
+}
+
+// Skip marks every item without an outcome as skipped.
+func (b *bulkBatch) Skip() {
+    for i := range b.Results {
+        if b.Results[i].Status == 0 {
+            b.Results[i].Status = http.StatusFailedDependency
+            b.Results[i].Error = "skipped after an earlier failure in ordered mode"
+        }
+    }
+}
+
+// Apply runs the queued writes and folds write errors into the item results.
+// In ordered mode the server stops at the first failing write, so later
+// queued items are marked skipped.
+func (b *bulkBatch) Apply(coll *mongo.Collection) error {
+    _, _, err := b.write(coll)
+    b.Skip()
+    return err
+}
+
+// ApplyGuarded runs the queued writes like Apply, for writes guarded by a
+// version filter. A guarded write that matches nothing is no error, and
+// BulkWrite only reports totals, so when fewer writes matched than ran
+// without an error, the documents are read back once: landed reports
+// whether the write of item i went through, given its document as it is now
+// (nil when it is gone). Items whose write did not land fail with 412, or
+// 404 when the document is gone. doc returns the document item i writes.
+func (b *bulkBatch) ApplyGuarded(coll *mongo.Collection, doc func(i int) primitive.ObjectID, landed func(i int, doc bson.M) bool) error {
+    ran, res, err := b.write(coll)
+    if err != nil {
+        return err
+    }
+    b.Skip()
+    var matched int64
+    if res != nil {
+        matched = res.MatchedCount + res.DeletedCount
+    }
+    if matched >= int64(len(ran)) {
+        return nil
+    }
+    ids := make([]primitive.ObjectID, len(ran))
+    for k, i := range ran {
+        ids[k] = doc(i)
+    }
+    current, err := fetchByIDs(coll, ids)
+    if err != nil {
+        return err
+    }
+    for _, i := range ran {
+        d, ok := current[doc(i)]
+        if landed(i, d) {
+            continue
+        }
+        if ok {
+            b.Fail(i, http.StatusPreconditionFailed, errors.New("document was modified concurrently"))
+        } else {
+            b.Fail(i, http.StatusNotFound, errors.New("not found"))
+        }
+    }
+    return nil
+}
+
+// write sends the queued writes in one BulkWrite and folds write errors into
+// the item results. It returns the items whose write ran without an error.
+func (b *bulkBatch) write(coll *mongo.Collection) ([]int, *mongo.BulkWriteResult, error) {
+    models := b.Models
+    if b.Ordered {
+        // only the items before the first failure are processed
+        n := 0
+        for n < len(models) && b.owners[n] < b.firstFailed {
+            n++
+        }
+        for m := n; m < len(models); m++ {
+            b.Results[b.owners[m]].Status = 0
+        }
+        models = models[:n]
+    }
+    if len(models) == 0 {
+        return nil, nil, nil
+    }
+    res, err := coll.BulkWrite(db.Ctx, models, options.BulkWrite().SetOrdered(b.Ordered))
+    var bwe mongo.BulkWriteException
+    if err != nil && !errors.As(err, &bwe) {
+        return nil, nil, err
+    }
+    if bwe.WriteConcernError != nil {
+        return nil, nil, bwe
+    }
+    failed := map[int]bool{}
+    first := len(models)
+    for _, we := range bwe.WriteErrors {
+        status := http.StatusInternalServerError
+        if mongo.IsDuplicateKeyError(we) {
+            status = http.StatusConflict
+        }
+        b.Fail(b.owners[we.Index], status, errors.New(we.Message))
+        failed[we.Index] = true
+        if we.Index < first {
+            first = we.Index
+        }
+    }
+    last := len(models)
+    if b.Ordered && first < last {
+        // the server stopped at the first error
+        for m := first + 1; m < last; m++ {
+            b.Results[b.owners[m]].Status = 0
+        }
+        last = first
+    }
+    var ran []int
+    for m := 0; m < last; m++ {
+        if !failed[m] {
+            ran = append(ran, b.owners[m])
+        }
+    }
+    return ran, res, nil
+}
+
+// Written returns the indexes of items whose queued write went through.
+func (b *bulkBatch) Written() []int {
+    var out []int
+    for _, i := range b.owners {
+        if b.Results[i].ok() {
+            out = append(out, i)
+        }
+    }
+    return out
+}
+
+// Respond writes the per-item results and a summary, plus any extra fields.
+func (b *bulkBatch) Respond(c *gin.Context, extra ...gin.H) {
+    succeeded := 0
+    for _, r := range b.Results {
+        if r.ok() {
+            succeeded++
+        }
+    }
+    body := gin.H{
+        "ordered":   b.Ordered,
+        "results":   b.Results,
+        "succeeded": succeeded,
+        "failed":    len(b.Results) - succeeded,
+    }
+    for _, e := range extra {
+        for k, v := range e {
+            body[k] = v
+        }
+    }
+    c.JSON(http.StatusOK, body)
+}
+
+// parseOrdered reads the ordered query parameter (default false).
+func parseOrdered(c *gin.Context) (bool, error) {
+    v := c.Query("ordered")
+    if v == "" {
+        return false, nil
+    }
+    ordered, err := strconv.ParseBool(v)
+    if err != nil {
+        return false, errors.New("ordered must be true or false")
+    }
+    return ordered, nil
+}
+
+// checkBulkSize rejects empty or oversized batches.
+func checkBulkSize(n int) error {
+    if n == 0 {
+        return errors.New("no items")
+    }
+    if n > maxBulkItems {
+        return fmt.Errorf("at most %d items per request", maxBulkItems)
+    }
+    return nil
+}
+
+// fetchByIDs loads the documents with the given ids, keyed by id.
+func fetchByIDs(coll *mongo.Collection, ids []primitive.ObjectID) (map[primitive.ObjectID]bson.M, error) {
+    out := make(map[primitive.ObjectID]bson.M, len(ids))
+    if len(ids) == 0 {
+        return out, nil
+    }
+    cur, err := coll.Find(db.Ctx, bson.M{"_id": bson.M{"$in": ids}})
+    if err != nil {
+        return nil, err
+    }
+    defer cur.Close(db.Ctx)
+    for cur.Next(db.Ctx) {
+        var raw bson.M
+        if err := cur.Decode(&raw); err != nil {
+            return nil, err
+        }
+        if id, ok := raw["_id"].(primitive.ObjectID); ok {
+            out[id] = raw
+        }
+    }
+    return out, cur.Err()
+}
+
+// parseIDs converts hex ids; errs[i] is set when hexes[i] is not a valid ObjectID.
+func parseIDs(hexes []string) (ids []primitive.ObjectID, errs []error) {
+    ids = make([]primitive.ObjectID, len(hexes))
+    errs = make([]error, len(hexes))
+    for i, h := range hexes {
+        oid, err := primitive.ObjectIDFromHex(h)
+        if err != nil {
+            errs[i] = errors.New("invalid id")
+            continue
+        }
+        ids[i] = oid
+    }
+    return ids, errs
+}
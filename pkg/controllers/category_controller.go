@@ -3,7 +3,6 @@ package controllers
 import (
     "errors"
     "net/http"
-    "strconv"
     "strings"
     "time"
 
@@ -15,15 +14,20 @@ import (
 
     "go-api/pkg/db"
     "go-api/pkg/models"
+    "go-api/pkg/query"
     "go-api/pkg/utils"
 )
 
 type CategoryController struct {
     Collection *mongo.Collection
+    Audit      *AuditLog
+    // LegacyPut makes PUT behave as a partial update for every client.
+    LegacyPut bool
 }
 
 func NewCategoryController(client *mongo.Client, dbName string) *CategoryController {
-    return &CategoryController{Collection: client.Database(dbName).Collection("categories")}
+    database := client.Database(dbName)
+    return &CategoryController{Collection: database.Collection("categories"), Audit: NewAuditLog(database)}
 }
 
 // CreateCategory creates a category
@@ -50,12 +54,17 @@ func (cc *CategoryController) CreateCategory(c *gin.Context) {
     now := time.Now().UTC()
     m.CreatedAt = now
     m.UpdatedAt = now
+    m.Version = 1
     res, err := cc.Collection.InsertOne(db.Ctx, m)
     if err != nil {
         utils.ServerError(c, err)
         return
     }
     m.ID = res.InsertedID.(primitive.ObjectID)
+    if after, err := toDoc(m); err == nil {
+        cc.Audit.Record(c, "categories", auditCreate, m.ID, nil, after)
+    }
+    setETag(c, m.Version)
     c.JSON(http.StatusCreated, m)
 }
 
@@ -66,8 +75,12 @@ func (cc *CategoryController) GetCategory(c *gin.Context) {
         utils.BadRequest(c, errors.New("invalid id"))
         return
     }
+    fields := parseFields(c, categoryFieldPaths)
+    if invalidParams(c) {
+        return
+    }
     var raw bson.M
-    if err := cc.Collection.FindOne(db.Ctx, bson.M{"_id": oid}).Decode(&raw); err != nil {
+    if err := cc.Collection.FindOne(db.Ctx, bson.M{"_id": oid}, fields.FindOne()).Decode(&raw); err != nil {
         if errors.Is(err, mongo.ErrNoDocuments) {
             utils.NotFound(c)
             return
@@ -75,71 +88,116 @@ func (cc *CategoryController) GetCategory(c *gin.Context) {
         utils.ServerError(c, err)
         return
     }
-    c.JSON(http.StatusOK, mapCategory(raw))
+    item, err := mapCategory(raw)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    if notModified(c, etag(item.Version), item.UpdatedAt) {
+        return
+    }
+    c.JSON(http.StatusOK, fields.Apply(item))
 }
 
 // ListCategories with pagination and sorting (name, createdAt)
 func (cc *CategoryController) ListCategories(c *gin.Context) {
-    filter := bson.M{}
-    if name := strings.TrimSpace(c.Query("name")); name != "" {
-        filter["name"] = bson.M{"$regex": name, "$options": "i"}
+    categoryList.List(c, cc.Collection)
+}
+
+// BatchGetCategories returns the categories with the ids of a {"ids": [...]} body
+func (cc *CategoryController) BatchGetCategories(c *gin.Context) {
+    categoryList.BatchGetJSON(c, cc.Collection)
+}
+
+// ExportCategories streams all matching categories as CSV or NDJSON (format=csv|ndjson)
+func (cc *CategoryController) ExportCategories(c *gin.Context) {
+    categoryList.Export(c, cc.Collection, categoryExporter)
+}
+
+// categoryList declares how categories are listed
+var categoryList = &listSpec{
+    Fields: map[string]listField{
+        "name":      {Type: query.String, Paths: []string{"name", "category_name"}},
+        "createdAt": {Type: query.Time, Paths: []string{"createdAt"}},
+        "updatedAt": {Type: query.Time, Paths: []string{"updatedAt"}},
+    },
+    Params: []listParam{
+        {Name: "name", Kind: paramContains, Field: "name"},
+    },
+    Sorts: map[string]sortKey{
+        "name":          {Path: "name", Text: true},
+        "category_name": {Path: "category_name", Text: true},
+        "createdAt":     {Path: "createdAt"},
+        "updatedAt":     {Path: "updatedAt"},
+    },
+    DefaultSort: "createdAt",
+    Paths:       categoryFieldPaths,
+    Item: func(raw bson.Raw) (interface{}, error) {
+        return decodeCategory(raw)
+    },
+}
+
+// mapCategory converts raw docs to Category, handling category_name alias
+func mapCategory(raw bson.M) (models.Category, error) {
+    b, err := bson.Marshal(raw)
+    if err != nil { return models.Category{}, err }
+    return decodeCategory(b)
+}
+
+// decodeCategory reads a stored category without going through bson.M
+func decodeCategory(raw bson.Raw) (models.Category, error) {
+    var out models.Category
+    err := out.UnmarshalBSON(raw)
+    return out, err
+}
+
+// UpdateCategory replaces a category; createdAt is kept
+func (cc *CategoryController) UpdateCategory(c *gin.Context) {
+    if cc.LegacyPut || legacyPutRequested(c) {
+        cc.mergeCategory(c)
+        return
     }
-    page := 1
-    limit := 10
-    if v, err := strconv.Atoi(c.DefaultQuery("page", "1")); err == nil && v > 0 {
-        page = v
+    oid, raw, ok := loadForWrite(c, cc.Collection)
+    if !ok {
+        return
     }
-    if v, err := strconv.Atoi(c.DefaultQuery("limit", "10")); err == nil && v > 0 && v <= 100 {
-        limit = v
+    type inBody struct {
+        Name         string `json:"name"`
+        CategoryName string `json:"category_name"`
     }
-    skip := int64((page - 1) * limit)
-
-    sortField := c.DefaultQuery("sort", "createdAt")
-    if sortField != "name" && sortField != "createdAt" {
-        sortField = "createdAt"
+    var body inBody
+    if err := c.ShouldBindJSON(&body); err != nil {
+        utils.BadRequest(c, err)
+        return
     }
-    order := c.DefaultQuery("order", "desc")
-    sortDir := int32(-1)
-    if strings.ToLower(order) == "asc" {
-        sortDir = 1
+    next := models.Category{ID: oid, Name: strings.TrimSpace(body.Name)}
+    if next.Name == "" {
+        next.Name = strings.TrimSpace(body.CategoryName)
     }
-    opts := options.Find().SetSkip(skip).SetLimit(int64(limit)).SetSort(bson.D{{Key: sortField, Value: sortDir}})
-
-    cur, err := cc.Collection.Find(db.Ctx, filter, opts)
-    if err != nil {
-        utils.ServerError(c, err)
+    if err := validate.Struct(next); err != nil {
+        utils.BadRequest(c, err)
         return
     }
-    defer cur.Close(db.Ctx)
-    var raws []bson.M
-    if err := cur.All(db.Ctx, &raws); err != nil {
+    prev, err := mapCategory(raw)
+    if err != nil {
         utils.ServerError(c, err)
         return
     }
-    cats := make([]models.Category, 0, len(raws))
-    for _, r := range raws { cats = append(cats, mapCategory(r)) }
-    total, err := cc.Collection.CountDocuments(db.Ctx, filter)
-    if err != nil {
-        utils.ServerError(c, err)
+    next.CreatedAt = prev.CreatedAt
+    next.UpdatedAt = time.Now().UTC()
+    next.Version = docVersion(raw) + 1
+    if !saveReplace(c, cc.Collection, cc.Audit, "categories", oid, raw, next) {
         return
     }
-    c.JSON(http.StatusOK, gin.H{"items": cats, "page": page, "limit": limit, "total": total})
-}
-
-// mapCategory converts raw docs to Category, handling category_name alias
-func mapCategory(raw bson.M) models.Category {
-    var out models.Category
-    if id, ok := raw["_id"].(primitive.ObjectID); ok { out.ID = id }
-    if n, ok := raw["name"].(string); ok && n != "" { out.Name = n } else if cn, ok := raw["category_name"].(string); ok { out.Name = cn }
-    if ct, ok := raw["createdAt"].(time.Time); ok { out.CreatedAt = ct }
-    if ut, ok := raw["updatedAt"].(time.Time); ok { out.UpdatedAt = ut }
-    if out.CreatedAt.IsZero() && out.ID != primitive.NilObjectID { out.CreatedAt = out.ID.Timestamp() }
-    if out.UpdatedAt.IsZero() { out.UpdatedAt = out.CreatedAt }
-    return out
+    if next.Name != prev.Name {
+        refreshReferrers(cc.Collection, db.CategorySort, oid)
+    }
+    setETag(c, next.Version)
+    c.JSON(http.StatusOK, next)
 }
 
-// UpdateCategory
-func (cc *CategoryController) UpdateCategory(c *gin.Context) {
+// mergeCategory is the pre-replace PUT behaviour: only a provided name is updated
+func (cc *CategoryController) mergeCategory(c *gin.Context) {
     oid, err := primitive.ObjectIDFromHex(c.Param("id"))
     if err != nil {
         utils.BadRequest(c, errors.New("invalid id"))
@@ -160,16 +218,78 @@ func (cc *CategoryController) UpdateCategory(c *gin.Context) {
     } else if n := strings.TrimSpace(body.CategoryName); n != "" {
         set["name"] = n
     }
-    res := cc.Collection.FindOneAndUpdate(db.Ctx, bson.M{"_id": oid}, bson.M{"$set": set}, options.FindOneAndUpdate().SetReturnDocument(options.After))
-    var out models.Category
-    if err := res.Decode(&out); err != nil {
+    filter, ok := versionedFilter(c, oid)
+    if !ok {
+        return
+    }
+    update := bson.M{"$set": set, "$inc": bson.M{"version": int64(1)}}
+    res := cc.Collection.FindOneAndUpdate(db.Ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.Before))
+    var before bson.M
+    if err := res.Decode(&before); err != nil {
         if errors.Is(err, mongo.ErrNoDocuments) {
-            utils.NotFound(c)
+            writeMiss(c, cc.Collection, oid)
             return
         }
         utils.ServerError(c, err)
         return
     }
+    after, err := applySet(before, set)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    cc.Audit.Record(c, "categories", auditUpdate, oid, before, after)
+    if _, ok := set["name"]; ok {
+        refreshReferrers(cc.Collection, db.CategorySort, oid)
+    }
+    out, err := mapCategory(after)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    setETag(c, out.Version)
+    c.JSON(http.StatusOK, out)
+}
+
+// PatchCategory applies a merge patch or JSON patch
+func (cc *CategoryController) PatchCategory(c *gin.Context) {
+    oid, raw, ok := loadForWrite(c, cc.Collection)
+    if !ok {
+        return
+    }
+    current, err := mapCategory(raw)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    var next models.Category
+    if err := decodePatch(c, current, &next); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    next.Name = strings.TrimSpace(next.Name)
+    if err := validate.Struct(next); err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    set, unset, err := patchUpdate(current, next)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    after, ok := savePatch(c, cc.Collection, cc.Audit, "categories", oid, raw, set, unset)
+    if !ok {
+        return
+    }
+    if _, ok := set["name"]; ok {
+        refreshReferrers(cc.Collection, db.CategorySort, oid)
+    }
+    out, err := mapCategory(after)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    setETag(c, out.Version)
     c.JSON(http.StatusOK, out)
 }
 
@@ -180,14 +300,20 @@ func (cc *CategoryController) DeleteCategory(c *gin.Context) {
         utils.BadRequest(c, errors.New("invalid id"))
         return
     }
-    res, err := cc.Collection.DeleteOne(db.Ctx, bson.M{"_id": oid})
-    if err != nil {
-        utils.ServerError(c, err)
+    filter, ok := versionedFilter(c, oid)
+    if !ok {
         return
     }
-    if res.DeletedCount == 0 {
-        utils.NotFound(c)
+    var before bson.M
+    if err := cc.Collection.FindOneAndDelete(db.Ctx, filter).Decode(&before); err != nil {
+        if errors.Is(err, mongo.ErrNoDocuments) {
+            writeMiss(c, cc.Collection, oid)
+            return
+        }
+        utils.ServerError(c, err)
         return
     }
+    cc.Audit.Record(c, "categories", auditDelete, oid, before, nil)
+    refreshReferrers(cc.Collection, db.CategorySort, oid)
     c.Status(http.StatusNoContent)
 }
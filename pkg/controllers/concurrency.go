@@ -0,0 +1,132 @@
+package controllers
+
+import (
+    "errors"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+
+    "go-api/pkg/db"
+    "go-api/pkg/utils"
+)
+
+// Documents carry a version counter that every write increments. It is exposed
+// as a strong ETag ("<version>") and checked against If-Match on writes.
+
+// etag formats a document version as a strong entity tag.
+func etag(version int64) string {
+    return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setETag sets the ETag response header for a document version.
+func setETag(c *gin.Context, version int64) {
+    c.Header("ETag", etag(version))
+}
+
+// docVersion reads the version of a raw document. Legacy documents without one are version 0.
+func docVersion(raw bson.M) int64 {
+    switch v := raw["version"].(type) {
+    case int32:
+        return int64(v)
+    case int64:
+        return v
+    case float64:
+        return int64(v)
+    }
+    return 0
+}
+
+// versionedFilter builds the filter for a write on oid, honoring If-Match.
+// Without If-Match (or with "*") only the id is matched. When the header is
+// invalid, or holds only weak tags that can never match, the response is
+// written and ok is false.
+func versionedFilter(c *gin.Context, oid primitive.ObjectID) (bson.M, bool) {
+    filter := bson.M{"_id": oid}
+    versions, err := ifMatchVersions(c)
+    if err != nil {
+        utils.BadRequest(c, err)
+        return nil, false
+    }
+    if versions == nil {
+        return filter, true
+    }
+    if len(versions) == 0 {
+        utils.PreconditionFailed(c)
+        return nil, false
+    }
+    ors := []bson.M{{"version": bson.M{"$in": versions}}}
+    for _, v := range versions {
+        if v == 0 {
+            ors = append(ors, bson.M{"version": bson.M{"$exists": false}})
+            break
+        }
+    }
+    filter["$or"] = ors
+    return filter, true
+}
+
+// currentVersionFilter matches oid only while it is still at version v, for
+// read-modify-write cycles.
+func currentVersionFilter(oid primitive.ObjectID, v int64) bson.M {
+    if v == 0 {
+        return bson.M{"_id": oid, "$or": []bson.M{{"version": int64(0)}, {"version": bson.M{"$exists": false}}}}
+    }
+    return bson.M{"_id": oid, "version": v}
+}
+
+// ifMatchOK reports whether a document at version v satisfies If-Match.
+func ifMatchOK(c *gin.Context, v int64) (bool, error) {
+    versions, err := ifMatchVersions(c)
+    if err != nil || versions == nil {
+        return err == nil, err
+    }
+    for _, want := range versions {
+        if want == v {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+// ifMatchVersions parses If-Match into versions. It returns nil when the
+// header is absent or "*", i.e. when any version is acceptable, and an
+// empty slice when it only holds weak tags.
+func ifMatchVersions(c *gin.Context) ([]int64, error) {
+    header := strings.TrimSpace(c.GetHeader("If-Match"))
+    if header == "" || header == "*" {
+        return nil, nil
+    }
+    versions := []int64{}
+    for _, tag := range strings.Split(header, ",") {
+        tag = strings.TrimSpace(tag)
+        // If-Match uses strong comparison, so weak tags never match.
+        if strings.HasPrefix(tag, "W/") {
+            continue
+        }
+        v, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+        if err != nil || v < 0 {
+            return nil, errors.New("invalid If-Match header")
+        }
+        versions = append(versions, v)
+    }
+    return versions, nil
+}
+
+// writeMiss answers a conditional write that matched nothing: 404 when the
+// document does not exist, 412 when it exists but its version has moved on.
+func writeMiss(c *gin.Context, coll *mongo.Collection, oid primitive.ObjectID) {
+    n, err := coll.CountDocuments(db.Ctx, bson.M{"_id": oid})
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    if n == 0 {
+        utils.NotFound(c)
+        return
+    }
+    utils.PreconditionFailed(c)
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+    "crypto/sha1"
+    "encoding/hex"
+    "encoding/json"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "go-api/pkg/utils"
+)
+
+// notModified sets the validators (ETag, Last-Modified) for a response and
+// answers 304 when the request's If-None-Match or If-Modified-Since shows the
+// client already has the current representation. If-Modified-Since is only
+// consulted when If-None-Match is absent (RFC 9110 13.1.3).
+func notModified(c *gin.Context, tag string, lastModified time.Time) bool {
+    c.Header("ETag", tag)
+    if !lastModified.IsZero() {
+        c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+    }
+    if inm := c.GetHeader("If-None-Match"); inm != "" {
+        if noneMatchHit(inm, tag) {
+            c.Status(http.StatusNotModified)
+            return true
+        }
+        return false
+    }
+    if ims := c.GetHeader("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+        // HTTP dates have second precision.
+        if t, err := http.ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(t) {
+            c.Status(http.StatusNotModified)
+            return true
+        }
+    }
+    return false
+}
+
+// noneMatchHit reports whether an If-None-Match header matches tag using weak comparison.
+func noneMatchHit(header, tag string) bool {
+    if strings.TrimSpace(header) == "*" {
+        return true
+    }
+    want := strings.TrimPrefix(tag, "W/")
+    for _, t := range strings.Split(header, ",") {
+        if strings.TrimPrefix(strings.TrimSpace(t), "W/") == want {
+            return true
+        }
+    }
+    return false
+}
+
+// respondList writes a list page with a weak ETag derived from its content,
+// answering 304 when the client's copy is still current. Lists send no
+// Last-Modified and ignore If-Modified-Since: deletions and items entering
+// or leaving the page change the content without changing any updatedAt.
+func respondList(c *gin.Context, body gin.H) {
+    b, err := json.Marshal(body)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    sum := sha1.Sum(b)
+    if notModified(c, `W/"`+hex.EncodeToString(sum[:8])+`"`, time.Time{}) {
+        return
+    }
+    c.Data(http.StatusOK, "application/json; charset=utf-8", b)
+}
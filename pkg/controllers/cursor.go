@@ -0,0 +1,345 @@
+package controllers
+
+import (
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "math"
+    "net/url"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+)
+
+// cursorToken is the decoded form of an after=/before= cursor: the sort it
+// was issued for and the sort key values of the item it points at.
+type cursorToken struct {
+    Sort   string        `bson:"s"`
+    Values []interface{} `bson:"v"`
+}
+
+// encodeCursor makes an opaque cursor pointing at raw under sort. BSON keeps
+// the value types (dates, numbers, ObjectIDs) intact across the round trip.
+func encodeCursor(sort bson.D, raw bson.Raw) string {
+    tok := cursorToken{Sort: sortSignature(sort)}
+    for _, k := range sort {
+        var v interface{}
+        if rv, err := raw.LookupErr(k.Key); err == nil {
+            if err := rv.Unmarshal(&v); err != nil {
+                return ""
+            }
+        }
+        tok.Values = append(tok.Values, v)
+    }
+    b, err := bson.Marshal(tok)
+    if err != nil {
+        return ""
+    }
+    return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string, sort bson.D) (*cursorToken, error) {
+    b, err := base64.RawURLEncoding.DecodeString(s)
+    if err != nil {
+        return nil, errors.New("invalid cursor")
+    }
+    var tok cursorToken
+    if err := bson.Unmarshal(b, &tok); err != nil {
+        return nil, errors.New("invalid cursor")
+    }
+    if tok.Sort != sortSignature(sort) || len(tok.Values) != len(sort) {
+        return nil, errors.New("cursor was issued for a different sort order")
+    }
+    return &tok, nil
+}
+
+func sortSignature(sort bson.D) string {
+    parts := make([]string, len(sort))
+    for i, k := range sort {
+        parts[i] = fmt.Sprintf("%s:%v", k.Key, k.Value)
+    }
+    return strings.Join(parts, ",")
+}
+
+// keysetSort appends _id as a tie-breaker so every item has a unique
+// position in the order, which keyset pagination relies on.
+func keysetSort(sort bson.D) bson.D {
+    for _, k := range sort {
+        if k.Key == "_id" {
+            return sort
+        }
+    }
+    dir := interface{}(int32(1))
+    if len(sort) > 0 {
+        dir = sort[len(sort)-1].Value
+    }
+    return append(append(bson.D{}, sort...), bson.E{Key: "_id", Value: dir})
+}
+
+func sortDir(v interface{}) int {
+    switch n := v.(type) {
+    case int32:
+        return int(n)
+    case int64:
+        return int(n)
+    case int:
+        return n
+    }
+    return 1
+}
+
+// keysetFilter matches the items strictly after values in the order given by
+// sort, or strictly before them when backward is set. Missing fields sort
+// like null, lowest of all, so they need explicit handling.
+func keysetFilter(sort bson.D, values []interface{}, backward bool) bson.M {
+    var branches []bson.M
+    for i, k := range sort {
+        branch := bson.M{}
+        for j := 0; j < i; j++ {
+            if values[j] == nil {
+                branch[sort[j].Key] = nil
+            } else {
+                branch[sort[j].Key] = values[j]
+            }
+        }
+        up := (sortDir(k.Value) > 0) != backward
+        v := values[i]
+        switch {
+        case v == nil && up:
+            branch[k.Key] = bson.M{"$ne": nil}
+        case v == nil:
+            continue // nothing sorts below null
+        case up:
+            branch[k.Key] = bson.M{"$gt": v}
+        default:
+            // Below a value: smaller values and null/missing.
+            below := bson.M{"$or": []bson.M{{k.Key: bson.M{"$lt": v}}, {k.Key: nil}}}
+            if len(branch) == 0 {
+                branch = below
+            } else {
+                branch = bson.M{"$and": []bson.M{branch, below}}
+            }
+        }
+        branches = append(branches, branch)
+    }
+    if len(branches) == 0 {
+        // Nothing can follow the position; match nothing.
+        return bson.M{"_id": bson.M{"$exists": false}}
+    }
+    return bson.M{"$or": branches}
+}
+
+func reverseSort(sort bson.D) bson.D {
+    out := make(bson.D, len(sort))
+    for i, k := range sort {
+        out[i] = bson.E{Key: k.Key, Value: int32(-sortDir(k.Value))}
+    }
+    return out
+}
+
+// pager holds the pagination of a list request: page/limit (offset) or an
+// after=/before= cursor (keyset).
+type pager struct {
+    Page  int
+    Limit int
+    Sort  bson.D
+    // Projection limits the fetched fields (nil for all); sort keys are
+    // always added since cursors are built from them.
+    Projection bson.M
+    // Derived computes sort keys that are not stored; the page is then
+    // read with an aggregation.
+    Derived   bson.A
+    Collation *options.Collation
+    cursor    *cursorToken
+    before    bool
+}
+
+// parsePager reads page, limit, after and before. sort is extended with an
+// _id tie-breaker; use p.Sort for queries.
+func parsePager(c *gin.Context, sort bson.D) *pager {
+    q := params(c)
+    p := &pager{Page: 1, Limit: 10, Sort: keysetSort(sort)}
+    if v, ok := q.Int("page", 1, math.MaxInt32); ok {
+        p.Page = v
+    }
+    if v, ok := q.Int("limit", 1, 100); ok {
+        p.Limit = v
+    }
+    after, before := c.Query("after"), c.Query("before")
+    switch {
+    case after != "" && before != "":
+        q.reject("before", "use either after or before, not both")
+    case after != "" || before != "":
+        param := "after"
+        if before != "" {
+            param = "before"
+        }
+        tok, err := decodeCursor(after+before, p.Sort)
+        if err != nil {
+            q.reject(param, err.Error())
+            break
+        }
+        p.cursor, p.before = tok, before != ""
+        p.Page = 0
+    }
+    return p
+}
+
+// pageResult is one fetched page and what lies around it.
+type pageResult struct {
+    // Items are the raw documents; decode them into models as needed.
+    Items   []bson.Raw
+    HasNext bool
+    HasPrev bool
+}
+
+// Find fetches the page matching filter. One extra item is read to learn
+// whether another page follows (or precedes, for before=).
+func (p *pager) Find(coll *mongo.Collection, filter bson.M) (pageResult, error) {
+    var cur *mongo.Cursor
+    var err error
+    if len(p.Derived) > 0 {
+        pipeline := append(bson.A{bson.M{"$match": filter}}, p.Derived...)
+        pipeline = append(pipeline, p.Stages()...)
+        cur, err = coll.Aggregate(db.Ctx, pipeline, options.Aggregate().SetCollation(p.Collation))
+    } else {
+        filter, sort, skip, proj := p.plan(filter)
+        opts := options.Find().SetLimit(int64(p.Limit + 1)).SetSort(sort).SetCollation(p.Collation)
+        if skip > 0 {
+            opts.SetSkip(skip)
+        }
+        if proj != nil {
+            opts.SetProjection(proj)
+        }
+        cur, err = coll.Find(db.Ctx, filter, opts)
+    }
+    if err != nil {
+        return pageResult{}, err
+    }
+    defer cur.Close(db.Ctx)
+    var items []bson.Raw
+    if err := cur.All(db.Ctx, &items); err != nil {
+        return pageResult{}, err
+    }
+    return p.Result(items), nil
+}
+
+// Stages returns the aggregation stages selecting the page within the
+// documents matching the list filter, after the Derived stages; read the
+// output with Result.
+func (p *pager) Stages() bson.A {
+    filter, sort, skip, proj := p.plan(bson.M{})
+    stages := bson.A{}
+    if len(filter) > 0 {
+        stages = append(stages, bson.M{"$match": filter})
+    }
+    stages = append(stages, bson.M{"$sort": sort})
+    if skip > 0 {
+        stages = append(stages, bson.M{"$skip": skip})
+    }
+    stages = append(stages, bson.M{"$limit": p.Limit + 1})
+    if proj != nil {
+        stages = append(stages, bson.M{"$project": proj})
+    }
+    return stages
+}
+
+// plan returns the page's filter, sort order, offset and projection.
+func (p *pager) plan(filter bson.M) (bson.M, bson.D, int64, bson.M) {
+    sort := p.Sort
+    var skip int64
+    if p.cursor != nil {
+        keyset := keysetFilter(p.Sort, p.cursor.Values, p.before)
+        if len(filter) > 0 {
+            filter = bson.M{"$and": []bson.M{filter, keyset}}
+        } else {
+            filter = keyset
+        }
+        if p.before {
+            sort = reverseSort(p.Sort)
+        }
+    } else {
+        skip = int64((p.Page - 1) * p.Limit)
+    }
+    var proj bson.M
+    if p.Projection != nil {
+        proj = bson.M{}
+        for k, v := range p.Projection {
+            proj[k] = v
+        }
+        for _, k := range p.Sort {
+            proj[k.Key] = 1
+        }
+    }
+    return filter, sort, skip, proj
+}
+
+// Result turns the (up to Limit+1) fetched items into the page.
+func (p *pager) Result(items []bson.Raw) pageResult {
+    res := pageResult{Items: items}
+    more := len(res.Items) > p.Limit
+    if more {
+        res.Items = res.Items[:p.Limit]
+    }
+    switch {
+    case p.cursor == nil:
+        res.HasNext, res.HasPrev = more, p.Page > 1
+    case p.before:
+        for i, j := 0, len(res.Items)-1; i < j; i, j = i+1, j-1 {
+            res.Items[i], res.Items[j] = res.Items[j], res.Items[i]
+        }
+        // The page we came from follows this one.
+        res.HasNext, res.HasPrev = true, more
+    default:
+        res.HasNext, res.HasPrev = more, true
+    }
+    return res
+}
+
+// Respond adds the pagination fields (page, limit, next, prev) to body and
+// sets an RFC 8288 Link header with first, next and prev links.
+func (p *pager) Respond(c *gin.Context, res pageResult, body gin.H) {
+    body["limit"] = p.Limit
+    if p.cursor == nil {
+        body["page"] = p.Page
+    }
+    links := []string{link(c, "", "", "first")}
+    if len(res.Items) > 0 {
+        if res.HasNext {
+            next := encodeCursor(p.Sort, res.Items[len(res.Items)-1])
+            body["next"] = next
+            links = append(links, link(c, "after", next, "next"))
+        }
+        if res.HasPrev {
+            prev := encodeCursor(p.Sort, res.Items[0])
+            body["prev"] = prev
+            links = append(links, link(c, "before", prev, "prev"))
+        }
+    }
+    c.Header("Link", strings.Join(links, ", "))
+}
+
+// link renders one Link header entry for the current request with the
+// pagination parameters replaced by param=value.
+func link(c *gin.Context, param, value, rel string) string {
+    q := url.Values{}
+    for k, vs := range c.Request.URL.Query() {
+        if k == "page" || k == "after" || k == "before" {
+            continue
+        }
+        q[k] = vs
+    }
+    if param != "" {
+        q.Set(param, value)
+    }
+    u := c.Request.URL.Path
+    if enc := q.Encode(); enc != "" {
+        u += "?" + enc
+    }
+    return fmt.Sprintf(`<%s>; rel="%s"`, u, rel)
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/utils"
+)
+
+// exportBatchSize is the cursor batch size; rows are flushed to the client
+// after every batch so memory use stays flat regardless of collection size.
+const exportBatchSize = 500
+
+// exporter describes how one resource is written out.
+type exporter struct {
+    name    string
+    columns []string
+    // item maps a raw document to the value written as NDJSON and its CSV
+    // row. Documents it fails on are left out of the export.
+    item func(raw bson.Raw) (interface{}, []string, error)
+}
+
+// export streams every document matching filter in the requested format
+// (csv or ndjson). The cursor is tied to the request context, so a client
+// disconnect stops the query.
+func export(c *gin.Context, coll *mongo.Collection, filter bson.M, ls listSort, ex exporter) {
+    format := c.DefaultQuery("format", "ndjson")
+    if format != "csv" && format != "ndjson" {
+        utils.BadRequest(c, errors.New("format must be csv or ndjson"))
+        return
+    }
+    ctx := c.Request.Context()
+    var cur *mongo.Cursor
+    var err error
+    if len(ls.Stages) > 0 {
+        // derived sort keys need an aggregation
+        pipeline := append(bson.A{bson.M{"$match": filter}}, ls.Stages...)
+        pipeline = append(pipeline, bson.M{"$sort": ls.Keys})
+        opts := options.Aggregate().SetBatchSize(exportBatchSize).SetAllowDiskUse(true).SetCollation(ls.Collation)
+        cur, err = coll.Aggregate(ctx, pipeline, opts)
+    } else {
+        opts := options.Find().SetSort(ls.Keys).SetBatchSize(exportBatchSize).SetCollation(ls.Collation)
+        cur, err = coll.Find(ctx, filter, opts)
+    }
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    defer cur.Close(ctx)
+
+    filename := ex.name + "-" + time.Now().UTC().Format("20060102") + "." + format
+    c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+    var write func(raw bson.Raw) error
+    var flush func() error
+    if format == "csv" {
+        c.Header("Content-Type", "text/csv; charset=utf-8")
+        w := csv.NewWriter(c.Writer)
+        if err := w.Write(ex.columns); err != nil {
+            return
+        }
+        write = func(raw bson.Raw) error {
+            _, row, err := ex.item(raw)
+            if err != nil {
+                skipUndecodable(ex.name, raw.Lookup("_id"), err)
+                return nil
+            }
+            return w.Write(row)
+        }
+        flush = func() error {
+            w.Flush()
+            return w.Error()
+        }
+    } else {
+        c.Header("Content-Type", "application/x-ndjson")
+        enc := json.NewEncoder(c.Writer)
+        write = func(raw bson.Raw) error {
+            item, _, err := ex.item(raw)
+            if err != nil {
+                skipUndecodable(ex.name, raw.Lookup("_id"), err)
+                return nil
+            }
+            return enc.Encode(item)
+        }
+        flush = func() error { return nil }
+    }
+    c.Status(http.StatusOK)
+
+    n := 0
+    for cur.Next(ctx) {
+        // cur.Current is only valid until the next call to Next
+        if err := write(cur.Current); err != nil {
+            log.Printf("export %s: %v", ex.name, err)
+            return
+        }
+        n++
+        if n%exportBatchSize == 0 {
+            if err := flush(); err != nil {
+                return
+            }
+            c.Writer.Flush()
+        }
+    }
+    if err := cur.Err(); err != nil {
+        // Headers are already sent; the truncated body is all we can signal.
+        log.Printf("export %s: %v", ex.name, err)
+    }
+    _ = flush()
+    c.Writer.Flush()
+}
+
+var animalExporter = exporter{
+    name:    "animals",
+    columns: []string{"id", "name", "species", "age", "adopted", "image", "owner", "lat", "lng", "createdAt", "updatedAt"},
+    item: func(raw bson.Raw) (interface{}, []string, error) {
+        a, err := decodeAnimal(raw)
+        if err != nil {
+            return nil, nil, err
+        }
+        lat, lng := "", ""
+        if a.Location != nil && len(a.Location.Coordinates) == 2 {
+            lng = strconv.FormatFloat(a.Location.Coordinates[0], 'f', -1, 64)
+            lat = strconv.FormatFloat(a.Location.Coordinates[1], 'f', -1, 64)
+        }
+        return a, []string{
+            a.ID.Hex(), a.Name, a.Species, strconv.Itoa(a.Age), strconv.FormatBool(a.Adopted),
+            a.Image, a.Owner, lat, lng, formatTime(a.CreatedAt), formatTime(a.UpdatedAt),
+        }, nil
+    },
+}
+
+var speciesExporter = exporter{
+    name:    "species",
+    columns: []string{"id", "name", "category", "createdAt", "updatedAt"},
+    item: func(raw bson.Raw) (interface{}, []string, error) {
+        s, err := decodeSpecies(raw)
+        return s, []string{s.ID.Hex(), s.Name, s.Category, formatTime(s.CreatedAt), formatTime(s.UpdatedAt)}, err
+    },
+}
+
+var categoryExporter = exporter{
+    name:    "categories",
+    columns: []string{"id", "name", "createdAt", "updatedAt"},
+    item: func(raw bson.Raw) (interface{}, []string, error) {
+        cat, err := decodeCategory(raw)
+        return cat, []string{cat.ID.Hex(), cat.Name, formatTime(cat.CreatedAt), formatTime(cat.UpdatedAt)}, err
+    },
+}
+
+func formatTime(t time.Time) string {
+    if t.IsZero() {
+        return ""
+    }
+    return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/bsontype"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+)
+
+// facet counts the documents matching a list filter per value of something.
+type facet struct {
+    // stages run on the matching documents inside $facet.
+    stages bson.A
+    // result turns the stage output into the response value.
+    result func(rows []bson.M) interface{}
+}
+
+// facetCount is one value of a facet and how many documents have it.
+type facetCount struct {
+    Value interface{} `json:"value"`
+    Name  string      `json:"name,omitempty"`
+    Count int         `json:"count"`
+}
+
+// parseFacets reads facets=a,b,... against the facets a resource offers.
+// It returns nil when the parameter is absent; unknown facets are recorded
+// as a bad parameter.
+func parseFacets(c *gin.Context, available map[string]facet) map[string]facet {
+    raw := strings.TrimSpace(c.Query("facets"))
+    if raw == "" {
+        return nil
+    }
+    out := map[string]facet{}
+    for _, name := range strings.Split(raw, ",") {
+        name = strings.TrimSpace(name)
+        if name == "" {
+            continue
+        }
+        f, ok := available[name]
+        if !ok {
+            allowed := make([]string, 0, len(available))
+            for k := range available {
+                allowed = append(allowed, k)
+            }
+            sort.Strings(allowed)
+            if len(allowed) == 0 {
+                params(c).reject("facets", "this resource has no facets")
+                return nil
+            }
+            params(c).reject("facets", fmt.Sprintf("unknown facet %q (allowed: %s)", name, strings.Join(allowed, ", ")))
+            continue
+        }
+        out[name] = f
+    }
+    return out
+}
+
+// findFaceted fetches the page, the total (when count is set) and the
+// facet counts for filter in a single aggregation.
+func findFaceted(coll *mongo.Collection, filter bson.M, pg *pager, facets map[string]facet, count bool) (pageResult, int64, gin.H, error) {
+    stages := bson.M{"items": append(append(bson.A{}, pg.Derived...), pg.Stages()...)}
+    if count {
+        stages["total"] = bson.A{bson.M{"$count": "n"}}
+    }
+    for name, f := range facets {
+        stages["facet_"+name] = f.stages
+    }
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: filter}},
+        {{Key: "$facet", Value: stages}},
+    }
+    cur, err := coll.Aggregate(db.Ctx, pipeline, options.Aggregate().SetCollation(pg.Collation))
+    if err != nil {
+        return pageResult{}, 0, nil, err
+    }
+    var out []bson.Raw
+    if err := cur.All(db.Ctx, &out); err != nil {
+        return pageResult{}, 0, nil, err
+    }
+    if len(out) == 0 {
+        return pageResult{}, 0, nil, fmt.Errorf("facet aggregation returned no document")
+    }
+    doc := out[0]
+    var total int64
+    if rows := facetRows(doc.Lookup("total")); len(rows) > 0 {
+        n, _ := toInt(rows[0]["n"])
+        total = int64(n)
+    }
+    counts := gin.H{}
+    for name, f := range facets {
+        counts[name] = f.result(facetRows(doc.Lookup("facet_"+name)))
+    }
+    items, err := rawDocs(doc.Lookup("items"))
+    if err != nil {
+        return pageResult{}, 0, nil, err
+    }
+    return pg.Result(items), total, counts, nil
+}
+
+func facetRows(v bson.RawValue) []bson.M {
+    var rows []bson.M
+    if v.Type == bsontype.Array {
+        _ = v.Unmarshal(&rows)
+    }
+    return rows
+}
+
+// rawDocs returns the documents of a BSON array, undecoded.
+func rawDocs(v bson.RawValue) ([]bson.Raw, error) {
+    arr, ok := v.ArrayOK()
+    if !ok {
+        return nil, nil
+    }
+    vals, err := arr.Values()
+    if err != nil {
+        return nil, err
+    }
+    docs := make([]bson.Raw, 0, len(vals))
+    for _, d := range vals {
+        if doc, ok := d.DocumentOK(); ok {
+            docs = append(docs, doc)
+        }
+    }
+    return docs, nil
+}
+
+// countRows reads _id/n rows as countRows.
+func countRows(rows []bson.M) []countRow {
+    out := make([]countRow, len(rows))
+    for i, r := range rows {
+        n, _ := toInt(r["n"])
+        out[i] = countRow{ID: r["_id"], N: n}
+    }
+    return out
+}
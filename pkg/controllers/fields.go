@@ -0,0 +1,134 @@
+package controllers
+
+import (
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fieldPaths maps each field of a resource's JSON representation to the
+// document fields the mapper reads to build it, aliases included.
+type fieldPaths map[string][]string
+
+// Fields every projection keeps: they feed the ETag, Last-Modified of
+// single documents and the createdAt fallback even when they are not
+// returned.
+var alwaysProjected = []string{"_id", "version", "createdAt", "updatedAt"}
+
+var animalFieldPaths = fieldPaths{
+    "id":        {"_id"},
+    "name":      {"name", "animal_name"},
+    "species":   {"species"},
+    "age":       {"age", "birthdate"},
+    "adopted":   {"adopted"},
+    "image":     {"image"},
+    "owner":     {"owner"},
+    "location":  {"location"},
+    "adoptedAt": {"adoptedAt"},
+    "createdAt": {"createdAt"},
+    "updatedAt": {"updatedAt"},
+    "version":   {"version"},
+}
+
+var speciesFieldPaths = fieldPaths{
+    "id":        {"_id"},
+    "name":      {"name", "species_name"},
+    "category":  {"category"},
+    "createdAt": {"createdAt"},
+    "updatedAt": {"updatedAt"},
+    "version":   {"version"},
+}
+
+var categoryFieldPaths = fieldPaths{
+    "id":        {"_id"},
+    "name":      {"name", "category_name"},
+    "createdAt": {"createdAt"},
+    "updatedAt": {"updatedAt"},
+    "version":   {"version"},
+}
+
+// fieldSet is a parsed fields= parameter. A nil *fieldSet means all fields.
+type fieldSet struct {
+    names      []string
+    projection bson.M
+}
+
+// parseFields reads fields=name,species,... into a fieldSet, or nil when the
+// parameter is absent. Unknown fields are recorded as a bad parameter.
+func parseFields(c *gin.Context, paths fieldPaths) *fieldSet {
+    raw := strings.TrimSpace(c.Query("fields"))
+    if raw == "" {
+        return nil
+    }
+    fs := &fieldSet{projection: bson.M{}}
+    for _, p := range alwaysProjected {
+        fs.projection[p] = 1
+    }
+    seen := map[string]bool{}
+    for _, name := range strings.Split(raw, ",") {
+        name = strings.TrimSpace(name)
+        if name == "" || seen[name] {
+            continue
+        }
+        docPaths, ok := paths[name]
+        if !ok {
+            allowed := make([]string, 0, len(paths))
+            for k := range paths {
+                allowed = append(allowed, k)
+            }
+            sort.Strings(allowed)
+            params(c).reject("fields", fmt.Sprintf("unknown field %q (allowed: %s)", name, strings.Join(allowed, ", ")))
+            continue
+        }
+        seen[name] = true
+        fs.names = append(fs.names, name)
+        for _, p := range docPaths {
+            fs.projection[p] = 1
+        }
+    }
+    return fs
+}
+
+// Projection returns the Mongo projection, or nil for all fields.
+func (fs *fieldSet) Projection() bson.M {
+    if fs == nil {
+        return nil
+    }
+    return fs.projection
+}
+
+// FindOne returns FindOne options applying the projection.
+func (fs *fieldSet) FindOne() *options.FindOneOptions {
+    opts := options.FindOne()
+    if fs != nil {
+        opts.SetProjection(fs.projection)
+    }
+    return opts
+}
+
+// Apply reduces a mapped item to the requested fields.
+func (fs *fieldSet) Apply(item interface{}) interface{} {
+    if fs == nil {
+        return item
+    }
+    b, err := json.Marshal(item)
+    if err != nil {
+        return item
+    }
+    var all map[string]json.RawMessage
+    if err := json.Unmarshal(b, &all); err != nil {
+        return item
+    }
+    out := make(map[string]json.RawMessage, len(fs.names))
+    for _, n := range fs.names {
+        if v, ok := all[n]; ok {
+            out[n] = v
+        }
+    }
+    return out
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+    "encoding/binary"
+    "errors"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+    "go-api/pkg/query"
+)
+
+// andFilter adds conditions that must all hold. Conditions go to $and so
+// that several of them can use $or without overwriting each other.
+func andFilter(filter bson.M, conds ...bson.M) {
+    and, _ := filter["$and"].([]bson.M)
+    filter["$and"] = append(and, conds...)
+}
+
+// applyFilterExpr compiles the filter= query parameter against fields and
+// adds it to filter. An invalid expression is recorded as a bad parameter;
+// the error returned is a failure to resolve names.
+func applyFilterExpr(c *gin.Context, filter bson.M, fields query.Fields) error {
+    expr := strings.TrimSpace(c.Query("filter"))
+    if expr == "" {
+        return nil
+    }
+    cond, err := query.CompileString(expr, fields)
+    var se *query.SyntaxError
+    var fe *query.FieldError
+    if errors.As(err, &se) || errors.As(err, &fe) {
+        params(c).rejectErr("filter", err)
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    andFilter(filter, cond)
+    return nil
+}
+
+// nameResolver resolves a reference given by name (e.g. species=cat) to the
+// ids of the documents in coll whose name fields equal it, ignoring case.
+func nameResolver(coll *mongo.Collection, nameFields ...string) func(string) (bson.A, error) {
+    return func(value string) (bson.A, error) {
+        exact := bson.M{"$regex": "^" + regexp.QuoteMeta(value) + "$", "$options": "i"}
+        or := make([]bson.M, 0, len(nameFields))
+        for _, f := range nameFields {
+            or = append(or, bson.M{f: exact})
+        }
+        cur, err := coll.Find(db.Ctx, bson.M{"$or": or}, options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(100))
+        if err != nil {
+            return nil, err
+        }
+        defer cur.Close(db.Ctx)
+        var ids bson.A
+        for cur.Next(db.Ctx) {
+            var doc struct {
+                ID primitive.ObjectID `bson:"_id"`
+            }
+            if err := cur.Decode(&doc); err != nil {
+                return nil, err
+            }
+            ids = append(ids, doc.ID.Hex(), doc.ID)
+        }
+        return ids, cur.Err()
+    }
+}
+
+// refParams adds key=a,b (any of) and key!=a,b (none of) conditions on the
+// reference field path. Values are ids or names resolved with resolve.
+// Negation also matches documents without the reference.
+func refParams(c *gin.Context, filter bson.M, key, path string, resolve func(string) (bson.A, error)) error {
+    if v := strings.TrimSpace(c.Query(key)); v != "" {
+        refs, err := refList(v, resolve)
+        if err != nil {
+            return err
+        }
+        andFilter(filter, bson.M{path: bson.M{"$in": refs}})
+    }
+    // species!=cat arrives as the parameter "species!"
+    if v := strings.TrimSpace(c.Query(key + "!")); v != "" {
+        refs, err := refList(v, resolve)
+        if err != nil {
+            return err
+        }
+        andFilter(filter, bson.M{path: bson.M{"$nin": refs}})
+    }
+    return nil
+}
+
+// refList expands comma-separated references into every stored form they
+// may take: the value itself, its ObjectID and the ids of matching names.
+func refList(s string, resolve func(string) (bson.A, error)) (bson.A, error) {
+    refs := bson.A{}
+    for _, v := range splitList(s) {
+        refs = append(refs, refValues(v)...)
+        if resolve == nil {
+            continue
+        }
+        ids, err := resolve(v)
+        if err != nil {
+            return nil, err
+        }
+        refs = append(refs, ids...)
+    }
+    return refs, nil
+}
+
+func refValues(s string) bson.A {
+    if oid, err := primitive.ObjectIDFromHex(s); err == nil {
+        return bson.A{s, oid}
+    }
+    return bson.A{s}
+}
+
+func splitList(s string) []string {
+    var out []string
+    for _, p := range strings.Split(s, ",") {
+        if p = strings.TrimSpace(p); p != "" {
+            out = append(out, p)
+        }
+    }
+    return out
+}
+
+// timeRangeParams adds the createdAfter (inclusive), createdBefore
+// (exclusive) and updatedSince (inclusive) conditions. Like the mappers,
+// they fall back to the ObjectID timestamp for documents without createdAt
+// and to createdAt for documents without updatedAt.
+func timeRangeParams(c *gin.Context, filter bson.M) {
+    q := params(c)
+    if t, ok := q.Time("createdAfter"); ok {
+        andFilter(filter, createdCond("$gte", t))
+    }
+    if t, ok := q.Time("createdBefore"); ok {
+        andFilter(filter, createdCond("$lt", t))
+    }
+    if t, ok := q.Time("updatedSince"); ok {
+        andFilter(filter, updatedCond("$gte", t))
+    }
+}
+
+func createdCond(op string, t time.Time) bson.M {
+    return bson.M{"$or": []bson.M{
+        {"createdAt": bson.M{op: t}},
+        {"createdAt": nil, "_id": bson.M{op: minObjectID(t)}},
+    }}
+}
+
+// minObjectID is the smallest ObjectID generated at t (whole seconds).
+func minObjectID(t time.Time) primitive.ObjectID {
+    var id primitive.ObjectID
+    binary.BigEndian.PutUint32(id[0:4], uint32(t.Unix()))
+    return id
+}
+
+func updatedCond(op string, t time.Time) bson.M {
+    return bson.M{"$or": []bson.M{
+        {"updatedAt": bson.M{op: t}},
+        {"updatedAt": nil, "createdAt": bson.M{op: t}},
+        {"updatedAt": nil, "createdAt": nil, "_id": bson.M{op: minObjectID(t)}},
+    }}
+}
+
+// existsParams adds hasX=true|false conditions; params maps each parameter
+// to its field. Empty strings count as absent.
+func existsParams(c *gin.Context, filter bson.M, fields map[string]string) {
+    for param, field := range fields {
+        has, ok := params(c).Bool(param)
+        if !ok {
+            continue
+        }
+        if has {
+            andFilter(filter, bson.M{field: bson.M{"$nin": bson.A{nil, ""}}})
+        } else {
+            andFilter(filter, bson.M{"$or": []bson.M{{field: nil}, {field: ""}}})
+        }
+    }
+}
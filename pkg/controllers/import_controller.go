@@ -0,0 +1,490 @@
+package controllers
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/dataset"
+    "go-api/pkg/db"
+    "go-api/pkg/models"
+    "go-api/pkg/utils"
+)
+
+const (
+    // maxImportRows caps the number of data rows accepted by one import.
+    maxImportRows = 5000
+    // maxImportBytes caps the size of an import request body.
+    maxImportBytes = 10 << 20
+)
+
+// Header aliases recognised per resource, mapped to the canonical column name.
+var (
+    animalColumns = map[string]string{
+        "name": "name", "animal_name": "name",
+        "species": "species", "species_id": "species", "species_name": "species_name",
+        "age": "age", "birthdate": "birthdate", "adopted": "adopted",
+        "image": "image", "owner": "owner",
+        "lat": "lat", "latitude": "lat",
+        "lng": "lng", "lon": "lng", "long": "lng", "longitude": "lng",
+    }
+    speciesColumns = map[string]string{
+        "name": "name", "species_name": "name",
+        "category": "category", "category_id": "category", "category_name": "category_name",
+    }
+    categoryColumns = map[string]string{
+        "name": "name", "category_name": "name",
+    }
+)
+
+type ImportController struct {
+    DB    *mongo.Database
+    Audit *AuditLog
+}
+
+func NewImportController(client *mongo.Client, dbName string) *ImportController {
+    database := client.Database(dbName)
+    return &ImportController{DB: database, Audit: NewAuditLog(database)}
+}
+
+// ImportAnimals godoc
+// @Summary Import animals from CSV
+// @Description The first row is a header. Recognised columns: name/animal_name, species, species_name
+// @Description (resolved to the species id), age, birthdate, adopted, image, owner, lat/latitude, lng/lon/longitude.
+// @Description Other headers can be mapped with map=Header:column,... With preview=true nothing is written.
+// @Tags import
+// @Accept text/csv,multipart/form-data
+// @Produce json
+// @Param file formData file false "CSV file (or send the CSV as the request body)"
+// @Param map query string false "Header mapping, e.g. Nimi:name,Laji:species_name"
+// @Param preview query bool false "Validate only"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Router /import/animals [post]
+func (ic *ImportController) ImportAnimals(c *gin.Context) {
+    var speciesIDs map[string]string
+    build := func(row map[string]string) (primitive.ObjectID, interface{}, error) {
+        var body animalInput
+        body.Name = row["name"]
+        body.Species = row["species"]
+        if name := row["species_name"]; name != "" && body.Species == "" {
+            if speciesIDs == nil {
+                var err error
+                if speciesIDs, err = nameIndex(ic.DB.Collection("species"), "species_name"); err != nil {
+                    return primitive.NilObjectID, nil, err
+                }
+            }
+            id, ok := speciesIDs[strings.ToLower(name)]
+            if !ok {
+                return primitive.NilObjectID, nil, fmt.Errorf("unknown species_name %q", name)
+            }
+            body.Species = id
+        }
+        body.Birthdate = row["birthdate"]
+        if v := row["age"]; v != "" {
+            age, err := strconv.Atoi(v)
+            if err != nil {
+                return primitive.NilObjectID, nil, errors.New("age must be an integer")
+            }
+            body.Age = &age
+        }
+        if v := row["adopted"]; v != "" {
+            adopted, err := parseBoolLoose(v)
+            if err != nil {
+                return primitive.NilObjectID, nil, err
+            }
+            body.Adopted = &adopted
+        }
+        body.Image = row["image"]
+        body.Owner = row["owner"]
+        if row["lat"] != "" || row["lng"] != "" {
+            lat, errLat := strconv.ParseFloat(row["lat"], 64)
+            lng, errLng := strconv.ParseFloat(row["lng"], 64)
+            if errLat != nil || errLng != nil {
+                return primitive.NilObjectID, nil, errors.New("lat and lng must both be numbers")
+            }
+            body.Location = &models.GeoPoint{Type: "Point", Coordinates: []float64{lng, lat}}
+        }
+        in := body.model()
+        if err := validate.Struct(in); err != nil {
+            return primitive.NilObjectID, nil, err
+        }
+        now := time.Now().UTC()
+        in.ID = primitive.NewObjectID()
+        in.CreatedAt, in.UpdatedAt, in.Version = now, now, 1
+        stampAdoption(nil, &in, now)
+        return in.ID, in, nil
+    }
+    ic.importCSV(c, "animals", animalColumns, build)
+}
+
+// ImportSpecies godoc
+// @Summary Import species from CSV
+// @Description Recognised columns: name/species_name, category, category_name (resolved to the category id).
+// @Tags import
+// @Accept text/csv,multipart/form-data
+// @Produce json
+// @Param file formData file false "CSV file (or send the CSV as the request body)"
+// @Param map query string false "Header mapping, e.g. Laji:name,Luokka:category_name"
+// @Param preview query bool false "Validate only"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Router /import/species [post]
+func (ic *ImportController) ImportSpecies(c *gin.Context) {
+    var categoryIDs map[string]string
+    build := func(row map[string]string) (primitive.ObjectID, interface{}, error) {
+        m := models.Species{Name: row["name"], Category: categoryRef(row["category"])}
+        if name := row["category_name"]; name != "" && m.Category == "" {
+            if categoryIDs == nil {
+                var err error
+                if categoryIDs, err = nameIndex(ic.DB.Collection("categories"), "category_name"); err != nil {
+                    return primitive.NilObjectID, nil, err
+                }
+            }
+            id, ok := categoryIDs[strings.ToLower(name)]
+            if !ok {
+                return primitive.NilObjectID, nil, fmt.Errorf("unknown category_name %q", name)
+            }
+            m.Category = id
+        }
+        if err := validate.Struct(m); err != nil {
+            return primitive.NilObjectID, nil, err
+        }
+        now := time.Now().UTC()
+        m.ID = primitive.NewObjectID()
+        m.CreatedAt, m.UpdatedAt, m.Version = now, now, 1
+        return m.ID, m, nil
+    }
+    ic.importCSV(c, "species", speciesColumns, build)
+}
+
+// ImportCategories godoc
+// @Summary Import categories from CSV
+// @Description Recognised columns: name/category_name.
+// @Tags import
+// @Accept text/csv,multipart/form-data
+// @Produce json
+// @Param file formData file false "CSV file (or send the CSV as the request body)"
+// @Param map query string false "Header mapping, e.g. Luokka:name"
+// @Param preview query bool false "Validate only"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Router /import/categories [post]
+func (ic *ImportController) ImportCategories(c *gin.Context) {
+    build := func(row map[string]string) (primitive.ObjectID, interface{}, error) {
+        m := models.Category{Name: row["name"]}
+        if err := validate.Struct(m); err != nil {
+            return primitive.NilObjectID, nil, err
+        }
+        now := time.Now().UTC()
+        m.ID = primitive.NewObjectID()
+        m.CreatedAt, m.UpdatedAt, m.Version = now, now, 1
+        return m.ID, m, nil
+    }
+    ic.importCSV(c, "categories", categoryColumns, build)
+}
+
+// ImportDataset godoc
+// @Summary Import raw dumps of the original dataset (admin)
+// @Description Multipart upload with any of the fields categories, species and animals, each a
+// @Description mongoexport JSON or mongodump BSON (.bson) file. Source ids are remapped consistently
+// @Description across collections and re-importing the same dumps updates documents in place.
+// @Tags import
+// @Accept multipart/form-data
+// @Produce json
+// @Param categories formData file false "Categories dump"
+// @Param species formData file false "Species dump"
+// @Param animals formData file false "Animals dump"
+// @Success 200 {object} dataset.Report
+// @Failure 400 {object} map[string]string
+// @Router /import/dataset [post]
+func (ic *ImportController) ImportDataset(c *gin.Context) {
+    var files dataset.Files
+    provided := 0
+    for _, f := range []struct {
+        field string
+        dst   *[]bson.M
+    }{{"categories", &files.Categories}, {"species", &files.Species}, {"animals", &files.Animals}} {
+        fh, err := c.FormFile(f.field)
+        if err != nil {
+            continue
+        }
+        src, err := fh.Open()
+        if err != nil {
+            utils.BadRequest(c, err)
+            return
+        }
+        docs, err := dataset.ReadFile(fh.Filename, src)
+        src.Close()
+        if err != nil {
+            utils.BadRequest(c, fmt.Errorf("%s: %w", f.field, err))
+            return
+        }
+        *f.dst = docs
+        provided++
+    }
+    if provided == 0 {
+        utils.BadRequest(c, errors.New("upload at least one of categories, species, animals"))
+        return
+    }
+    im := dataset.NewImporter(ic.DB)
+    im.Audit = func(collection, action string, id primitive.ObjectID, before, after bson.M) {
+        ic.Audit.Record(c, collection, action, id, before, after)
+    }
+    rep, err := im.Run(c.Request.Context(), files)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, rep)
+}
+
+// importCSV reads the uploaded CSV, builds a document per data row and, unless
+// previewing, inserts the valid rows. Results are reported per row, with Index
+// being the 0-based data row and Row the line number in the file.
+func (ic *ImportController) importCSV(c *gin.Context, collection string, columns map[string]string, build func(map[string]string) (primitive.ObjectID, interface{}, error)) {
+    preview := false
+    if v := c.Query("preview"); v != "" {
+        p, err := strconv.ParseBool(v)
+        if err != nil {
+            utils.BadRequest(c, errors.New("preview must be true or false"))
+            return
+        }
+        preview = p
+    }
+    mapping, err := parseColumnMap(c.Query("map"), columns)
+    if err != nil {
+        utils.BadRequest(c, err)
+        return
+    }
+    c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+    src, err := csvSource(c)
+    if err != nil {
+        if !importTooLarge(c, err) {
+            utils.BadRequest(c, err)
+        }
+        return
+    }
+    defer src.Close()
+
+    r := csv.NewReader(src)
+    r.FieldsPerRecord = -1
+    r.TrimLeadingSpace = true
+    header, err := r.Read()
+    if err != nil {
+        if !importTooLarge(c, err) {
+            utils.BadRequest(c, fmt.Errorf("cannot read CSV header: %w", err))
+        }
+        return
+    }
+    keys := make([]string, len(header))
+    known := 0
+    for i, h := range header {
+        h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+        if k, ok := mapping[h]; ok {
+            keys[i] = k
+        } else {
+            keys[i] = columns[strings.ToLower(h)]
+        }
+        if keys[i] != "" {
+            known++
+        }
+    }
+    if known == 0 {
+        utils.BadRequest(c, errors.New("no recognised columns in CSV header"))
+        return
+    }
+
+    var records [][]string
+    var readErrs []error
+    // lines are where each record starts; quoted fields may span lines
+    var lines []int
+    for {
+        rec, err := r.Read()
+        if err == io.EOF {
+            break
+        }
+        if len(records) >= maxImportRows {
+            utils.TooLarge(c, fmt.Errorf("at most %d rows per import", maxImportRows))
+            return
+        }
+        // Malformed rows are reported individually; the reader can continue past them.
+        var pe *csv.ParseError
+        if err != nil && !errors.As(err, &pe) {
+            if !importTooLarge(c, err) {
+                utils.BadRequest(c, err)
+            }
+            return
+        }
+        line := 0
+        if pe != nil {
+            line = pe.StartLine
+        } else if len(rec) > 0 {
+            line, _ = r.FieldPos(0)
+        }
+        records = append(records, rec)
+        readErrs = append(readErrs, err)
+        lines = append(lines, line)
+    }
+    if len(records) == 0 {
+        utils.BadRequest(c, errors.New("no data rows"))
+        return
+    }
+
+    batch := newBulkBatch(len(records), false)
+    docs := make([]interface{}, len(records))
+    ids := make([]primitive.ObjectID, len(records))
+    for i, rec := range records {
+        batch.Results[i].Row = lines[i]
+        if readErrs[i] != nil {
+            batch.Fail(i, http.StatusBadRequest, readErrs[i])
+            continue
+        }
+        if len(rec) != len(keys) {
+            batch.Fail(i, http.StatusBadRequest, fmt.Errorf("expected %d fields, got %d", len(keys), len(rec)))
+            continue
+        }
+        row := map[string]string{}
+        for j, v := range rec {
+            if keys[j] != "" {
+                row[keys[j]] = strings.TrimSpace(v)
+            }
+        }
+        id, doc, err := build(row)
+        if err != nil {
+            batch.Fail(i, http.StatusBadRequest, err)
+            continue
+        }
+        ids[i], docs[i] = id, doc
+        if preview {
+            batch.Done(i, http.StatusOK, "")
+            continue
+        }
+        batch.Queue(i, http.StatusCreated, id.Hex(), mongo.NewInsertOneModel().SetDocument(doc))
+    }
+    if !preview {
+        if err := batch.Apply(ic.DB.Collection(collection)); err != nil {
+            utils.ServerError(c, err)
+            return
+        }
+        written := batch.Written()
+        created := make([]interface{}, 0, len(written))
+        for _, i := range written {
+            created = append(created, ids[i])
+            if after, err := toDoc(docs[i]); err == nil {
+                ic.Audit.Record(c, collection, auditCreate, ids[i], nil, after)
+            }
+        }
+        for _, r := range db.SortKeys {
+            if r.From == collection {
+                refreshSortKeys(ic.DB.Collection(collection), r, created...)
+            }
+        }
+    }
+    batch.Respond(c, gin.H{"preview": preview})
+}
+
+// csvSource returns the CSV from a multipart "file" field or the raw body.
+func csvSource(c *gin.Context) (io.ReadCloser, error) {
+    if strings.HasPrefix(c.ContentType(), "multipart/") {
+        fh, err := c.FormFile("file")
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            return nil, err
+        }
+        if err != nil {
+            return nil, errors.New("missing file field")
+        }
+        return fh.Open()
+    }
+    return c.Request.Body, nil
+}
+
+// importTooLarge answers 413 and reports true when err comes from a body
+// larger than maxImportBytes.
+func importTooLarge(c *gin.Context, err error) bool {
+    var tooLarge *http.MaxBytesError
+    if !errors.As(err, &tooLarge) {
+        return false
+    }
+    utils.TooLarge(c, fmt.Errorf("import is larger than %d bytes", maxImportBytes))
+    return true
+}
+
+// parseColumnMap parses "Header:column,..." into header -> canonical column,
+// accepting only columns the resource knows.
+func parseColumnMap(s string, columns map[string]string) (map[string]string, error) {
+    out := map[string]string{}
+    if strings.TrimSpace(s) == "" {
+        return out, nil
+    }
+    for _, pair := range strings.Split(s, ",") {
+        header, field, ok := strings.Cut(pair, ":")
+        header, field = strings.TrimSpace(header), strings.ToLower(strings.TrimSpace(field))
+        if !ok || header == "" {
+            return nil, fmt.Errorf("invalid map entry %q", pair)
+        }
+        canonical, known := columns[field]
+        if !known {
+            return nil, fmt.Errorf("unknown column %q in map", field)
+        }
+        out[header] = canonical
+    }
+    return out, nil
+}
+
+// nameIndex maps lower-cased names (name or the legacy alias field) to ids.
+func nameIndex(coll *mongo.Collection, alias string) (map[string]string, error) {
+    opts := options.Find().SetProjection(bson.M{"name": 1, alias: 1})
+    cur, err := coll.Find(db.Ctx, bson.M{}, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cur.Close(db.Ctx)
+    out := map[string]string{}
+    for cur.Next(db.Ctx) {
+        var raw bson.M
+        if err := cur.Decode(&raw); err != nil {
+            return nil, err
+        }
+        id, ok := raw["_id"].(primitive.ObjectID)
+        if !ok {
+            continue
+        }
+        for _, key := range []string{"name", alias} {
+            if n, ok := raw[key].(string); ok && n != "" {
+                out[strings.ToLower(n)] = id.Hex()
+            }
+        }
+    }
+    return out, cur.Err()
+}
+
+// parseBoolLoose accepts the usual spreadsheet spellings of booleans.
+func parseBoolLoose(s string) (bool, error) {
+    switch strings.ToLower(s) {
+    case "yes", "y", "kyllä", "x":
+        return true, nil
+    case "no", "n", "ei":
+        return false, nil
+    }
+    b, err := strconv.ParseBool(s)
+    if err != nil {
+        return false, fmt.Errorf("invalid boolean %q", s)
+    }
+    return b, nil
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+    "bytes"
+    "encoding/json"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestParseColumnMap(t *testing.T) {
+    tests := []struct {
+        in   string
+        want map[string]string
+        err  string
+    }{
+        {"", map[string]string{}, ""},
+        {"Nimi:name, Laji : species_name", map[string]string{"Nimi": "name", "Laji": "species_name"}, ""},
+        // aliases map to their canonical column, case-insensitively
+        {"Eläin:ANIMAL_NAME,Pituusaste:lon", map[string]string{"Eläin": "name", "Pituusaste": "lng"}, ""},
+        {"Nimi", nil, `invalid map entry "Nimi"`},
+        {":name", nil, `invalid map entry ":name"`},
+        {"Väri:colour", nil, `unknown column "colour" in map`},
+    }
+    for _, tt := range tests {
+        got, err := parseColumnMap(tt.in, animalColumns)
+        if tt.err != "" {
+            if err == nil || err.Error() != tt.err {
+                t.Errorf("parseColumnMap(%q) error = %v, want %q", tt.in, err, tt.err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("parseColumnMap(%q): %v", tt.in, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("parseColumnMap(%q) = %v, want %v", tt.in, got, tt.want)
+        }
+    }
+}
+
+type importResponse struct {
+    Preview   bool         `json:"preview"`
+    Succeeded int          `json:"succeeded"`
+    Failed    int          `json:"failed"`
+    Results   []bulkResult `json:"results"`
+}
+
+// runImport posts body to handler with contentType and returns the status and the
+// decoded response.
+func runImport(t *testing.T, handler gin.HandlerFunc, target, contentType string, body []byte) (int, importResponse) {
+    t.Helper()
+    w := httptest.NewRecorder()
+    c, _ := gin.CreateTestContext(w)
+    c.Request = httptest.NewRequest("POST", target, bytes.NewReader(body))
+    c.Request.Header.Set("Content-Type", contentType)
+    handler(c)
+    var resp importResponse
+    if w.Code == http.StatusOK {
+        if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return w.Code, resp
+}
+
+func TestImportPreview(t *testing.T) {
+    species := primitive.NewObjectID().Hex()
+    csv := "Nimi,species,age,adopted,lat,lng\n" +
+        "Misu," + species + ",3,yes,60.17,24.94\n" +
+        "Rekku," + species + ",abc,no,,\n" +
+        "\"Black\nJack\"," + species + ",2,maybe,,\n" +
+        "Nalle," + species + ",1,false,60.1,\n" +
+        "," + species + ",1,false,,\n" +
+        "Pörrö," + species + "\n" +
+        "Tassu," + species + ",4,true,,\n"
+    // preview writes nothing, so no database is needed
+    ic := &ImportController{}
+    status, resp := runImport(t, ic.ImportAnimals, "/import/animals?preview=true&map=Nimi:name", "text/csv", []byte(csv))
+    if status != http.StatusOK {
+        t.Fatalf("status %d", status)
+    }
+    if !resp.Preview || resp.Succeeded != 2 || resp.Failed != 5 {
+        t.Errorf("preview %v, %d succeeded, %d failed; want true, 2, 5", resp.Preview, resp.Succeeded, resp.Failed)
+    }
+    want := []struct {
+        row, status int
+        err         string
+    }{
+        {2, 200, ""},
+        {3, 400, "age"},
+        // the quoted name spans lines 4 and 5
+        {4, 400, "invalid boolean"},
+        {6, 400, "lat and lng"},
+        {7, 400, "Name"},
+        {8, 400, "expected 6 fields, got 2"},
+        {9, 200, ""},
+    }
+    if len(resp.Results) != len(want) {
+        t.Fatalf("%d results, want %d: %+v", len(resp.Results), len(want), resp.Results)
+    }
+    for i, w := range want {
+        r := resp.Results[i]
+        if r.Index != i || r.Row != w.row || r.Status != w.status || !strings.Contains(r.Error, w.err) || (w.err == "") != (r.Error == "") {
+            t.Errorf("result %d = %+v, want row %d, status %d, error containing %q", i, r, w.row, w.status, w.err)
+        }
+        if r.Status == 200 && r.ID != "" {
+            t.Errorf("result %d: preview returned id %s", i, r.ID)
+        }
+    }
+}
+
+func TestImportWritesValidRows(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    mt.Run("categories", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+        ic := &ImportController{DB: mt.DB}
+        csv := "Luokka,extra\nMammals,x\n\"broken\"quote,x\nBirds,y\n"
+        status, resp := runImport(t, ic.ImportCategories, "/import/categories?map=Luokka:name", "text/csv", []byte(csv))
+        if status != http.StatusOK {
+            t.Fatalf("status %d", status)
+        }
+        statuses := []int{resp.Results[0].Status, resp.Results[1].Status, resp.Results[2].Status}
+        if want := []int{201, 400, 201}; !sameStatuses(statuses, want) {
+            t.Errorf("statuses = %v, want %v", statuses, want)
+        }
+        inserts := writeCommands(mt, "insert")
+        if len(inserts) != 1 {
+            t.Fatalf("%d insert commands, want 1", len(inserts))
+        }
+        if docs, _ := inserts[0].Lookup("documents").Array().Values(); len(docs) != 2 {
+            t.Errorf("%d documents inserted, want the 2 valid rows", len(docs))
+        }
+    })
+}
+
+func TestImportLimits(t *testing.T) {
+    ic := &ImportController{}
+
+    var rows strings.Builder
+    rows.WriteString("name\n")
+    for i := 0; i <= maxImportRows; i++ {
+        rows.WriteString("Misu\n")
+    }
+    if status, _ := runImport(t, ic.ImportCategories, "/import/categories?preview=true", "text/csv", []byte(rows.String())); status != http.StatusRequestEntityTooLarge {
+        t.Errorf("%d rows: status %d, want 413", maxImportRows+1, status)
+    }
+
+    big := "name\n" + strings.Repeat("x", maxImportBytes) + "\n"
+    if status, _ := runImport(t, ic.ImportCategories, "/import/categories?preview=true", "text/csv", []byte(big)); status != http.StatusRequestEntityTooLarge {
+        t.Errorf("oversized body: status %d, want 413", status)
+    }
+
+    var form bytes.Buffer
+    mw := multipart.NewWriter(&form)
+    fw, _ := mw.CreateFormFile("file", "categories.csv")
+    fw.Write([]byte(big))
+    mw.Close()
+    if status, _ := runImport(t, ic.ImportCategories, "/import/categories?preview=true", mw.FormDataContentType(), form.Bytes()); status != http.StatusRequestEntityTooLarge {
+        t.Errorf("oversized upload: status %d, want 413", status)
+    }
+
+    // just under the row limit is accepted
+    var ok strings.Builder
+    ok.WriteString("name\n")
+    for i := 0; i < maxImportRows; i++ {
+        ok.WriteString("Misu\n")
+    }
+    if status, resp := runImport(t, ic.ImportCategories, "/import/categories?preview=true", "text/csv", []byte(ok.String())); status != http.StatusOK || resp.Succeeded != maxImportRows {
+        t.Errorf("%d rows: status %d, %d succeeded", maxImportRows, status, resp.Succeeded)
+    }
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+    "math"
+    "regexp"
+    "strings"
+    "sync"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+    "go-api/pkg/query"
+    "go-api/pkg/utils"
+)
+
+// listSpec declares how a resource is listed. The list engine derives from
+// it the plain filter parameters, the filter= whitelist, sorting, fields=,
+// facets= and the paginated response, so every resource gets the same
+// features; a new resource only needs a listSpec and a route.
+//
+// Every resource also gets batch get by ids, the createdAfter, createdBefore and updatedSince
+// ranges, page/limit or cursor pagination, order=asc|desc and
+// count=exact|estimated|none.
+type listSpec struct {
+    // Fields is the filter= whitelist.
+    Fields map[string]listField
+    // Params are the plain filter parameters such as name= or adopted=.
+    Params []listParam
+    // Sorts are the accepted sort= keys.
+    Sorts map[string]sortKey
+    // DefaultSort applies without sort=, in descending order unless order=asc.
+    DefaultSort string
+    // Paths maps the fields= names to the document paths they read.
+    Paths fieldPaths
+    // Facets are the facets= counts offered, if any.
+    Facets map[string]facet
+    // Item maps a document to its representation. Documents it fails on are
+    // left out of the page.
+    Item func(raw bson.Raw) (interface{}, error)
+}
+
+// listField is a filterable field. Paths lists the document paths holding
+// it, legacy aliases after the primary one.
+type listField struct {
+    Type  query.Type
+    Paths []string
+    // Ref, for query.Ref fields, is where names of referenced documents are
+    // resolved, so that species=cat matches the species named "Cat".
+    Ref *refTarget
+}
+
+// refTarget is a referenced collection and the fields holding its names.
+type refTarget struct {
+    Collection string
+    Names      []string
+}
+
+func (r *refTarget) resolver(database *mongo.Database) func(string) (bson.A, error) {
+    if r == nil {
+        return nil
+    }
+    return nameResolver(database.Collection(r.Collection), r.Names...)
+}
+
+type paramKind int
+
+const (
+    // paramContains matches a case-insensitive literal substring of any path.
+    paramContains paramKind = iota
+    // paramRef matches any of comma-separated ids or names; Name!= excludes.
+    paramRef
+    // paramBool matches true or false.
+    paramBool
+    // paramMin and paramMax bound an integer, inclusive.
+    paramMin
+    paramMax
+    // paramExists requires the path to be set (true) or unset (false).
+    paramExists
+)
+
+// listParam is a plain filter parameter on a listSpec field.
+type listParam struct {
+    Name  string
+    Kind  paramKind
+    Field string
+    // Paths overrides the field's paths, for parameters on paths that are
+    // not filter= fields (e.g. hasLocation).
+    Paths []string
+}
+
+// queryFields returns the filter= whitelist for database.
+func (s *listSpec) queryFields(database *mongo.Database) query.Fields {
+    fields := make(query.Fields, len(s.Fields))
+    for name, f := range s.Fields {
+        fields[name] = query.Field{Type: f.Type, Paths: f.Paths, Resolve: f.Ref.resolver(database)}
+    }
+    return fields
+}
+
+// Filter builds the Mongo filter from the list query parameters. Invalid
+// parameters are recorded (see invalidParams); the error is a failure to
+// resolve names.
+func (s *listSpec) Filter(c *gin.Context, database *mongo.Database) (bson.M, error) {
+    q := params(c)
+    filter := bson.M{}
+    type bound struct {
+        param string
+        value int
+    }
+    mins := map[string]bound{}
+    for _, p := range s.Params {
+        f := s.Fields[p.Field]
+        paths := f.Paths
+        if p.Paths != nil {
+            paths = p.Paths
+        }
+        switch p.Kind {
+        case paramContains:
+            v := strings.TrimSpace(c.Query(p.Name))
+            if v == "" {
+                continue
+            }
+            // a literal substring: (, [ or * must not reach Mongo as regex syntax
+            ors := make([]bson.M, len(paths))
+            for i, path := range paths {
+                ors[i] = bson.M{path: bson.M{"$regex": regexp.QuoteMeta(v), "$options": "i"}}
+            }
+            andFilter(filter, bson.M{"$or": ors})
+        case paramRef:
+            if err := refParams(c, filter, p.Name, paths[0], f.Ref.resolver(database)); err != nil {
+                return nil, err
+            }
+        case paramBool:
+            if v, ok := q.Bool(p.Name); ok {
+                andFilter(filter, bson.M{paths[0]: v})
+            }
+        case paramMin:
+            if v, ok := q.Int(p.Name, 0, math.MaxInt32); ok {
+                mins[p.Field] = bound{p.Name, v}
+                andFilter(filter, bson.M{paths[0]: bson.M{"$gte": v}})
+            }
+        case paramMax:
+            v, ok := q.Int(p.Name, 0, math.MaxInt32)
+            if !ok {
+                continue
+            }
+            if min, hasMin := mins[p.Field]; hasMin && v < min.value {
+                q.invalid(p.Name, "must not be less than "+min.param)
+                continue
+            }
+            andFilter(filter, bson.M{paths[0]: bson.M{"$lte": v}})
+        case paramExists:
+            existsParams(c, filter, map[string]string{p.Name: paths[0]})
+        }
+    }
+    timeRangeParams(c, filter)
+    if err := applyFilterExpr(c, filter, s.queryFields(database)); err != nil {
+        return nil, err
+    }
+    return filter, nil
+}
+
+// List serves a paginated list of the documents of coll matching the
+// request, or with ids= the documents with those ids (see BatchGetQuery).
+func (s *listSpec) List(c *gin.Context, coll *mongo.Collection) {
+    if _, ok := c.GetQuery("ids"); ok {
+        s.BatchGetQuery(c, coll)
+        return
+    }
+    filter, err := s.Filter(c, coll.Database())
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+
+    // pagination: page/limit, or an after/before cursor
+    ls := s.Sort(c)
+    pg := parsePager(c, ls.Keys)
+    pg.Derived, pg.Collation = ls.Stages, ls.Collation
+    fields := parseFields(c, s.Paths)
+    pg.Projection = fields.Projection()
+    facets := parseFacets(c, s.Facets)
+    countMode := params(c).Enum("count", "exact", "exact", "estimated", "none")
+    if invalidParams(c) {
+        return
+    }
+
+    var res pageResult
+    var total int64
+    var counts gin.H
+    estimated := false
+    switch {
+    case facets != nil:
+        // items, total and facet counts in one aggregation
+        res, total, counts, err = findFaceted(coll, filter, pg, facets, countMode != "none")
+    case countMode == "none":
+        // hasMore comes from the extra item Find reads
+        res, err = pg.Find(coll, filter)
+    default:
+        var countErr error
+        var wg sync.WaitGroup
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if countMode == "estimated" && len(filter) == 0 {
+                // from collection metadata, without scanning
+                total, countErr = coll.EstimatedDocumentCount(db.Ctx)
+                estimated = true
+                return
+            }
+            total, countErr = coll.CountDocuments(db.Ctx, filter, options.Count().SetCollation(ls.Collation))
+        }()
+        res, err = pg.Find(coll, filter)
+        wg.Wait()
+        if err == nil {
+            err = countErr
+        }
+    }
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+
+    items := make([]interface{}, 0, len(res.Items))
+    for _, r := range res.Items {
+        item, err := s.Item(r)
+        if err != nil {
+            skipUndecodable(coll.Name(), r.Lookup("_id"), err)
+            continue
+        }
+        items = append(items, fields.Apply(item))
+    }
+
+    body := gin.H{
+        "items":   items,
+        "hasMore": res.HasNext,
+    }
+    if countMode != "none" {
+        body["total"] = total
+    }
+    if estimated {
+        body["totalEstimated"] = true
+    }
+    if counts != nil {
+        body["facets"] = counts
+    }
+    pg.Respond(c, res, body)
+    respondList(c, body)
+}
+
+// Export streams the documents of coll matching the request with ex.
+func (s *listSpec) Export(c *gin.Context, coll *mongo.Collection, ex exporter) {
+    filter, err := s.Filter(c, coll.Database())
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    ls := s.Sort(c)
+    if invalidParams(c) {
+        return
+    }
+    export(c, coll, filter, ls, ex)
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+    "context"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDB returns a database handle; the client connects lazily, so building
+// filters works without a server.
+func testDB(t *testing.T) *mongo.Database {
+    t.Helper()
+    client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:1"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+    return client.Database("test")
+}
+
+func testContext(target string) *gin.Context {
+    c, _ := gin.CreateTestContext(httptest.NewRecorder())
+    c.Request = httptest.NewRequest("GET", target, nil)
+    return c
+}
+
+func TestContainsParamIsLiteral(t *testing.T) {
+    tests := map[string]string{
+        "/animals?name=lu":      "lu",
+        "/animals?name=(":       `\(`,
+        "/animals?name=a.b*":    `a\.b\*`,
+        "/animals?name=%5Bx%5D": `\[x\]`,
+    }
+    for target, want := range tests {
+        c := testContext(target)
+        filter, err := animalList.Filter(c, testDB(t))
+        if err != nil {
+            t.Fatalf("%s: %v", target, err)
+        }
+        if len(params(c).errs) > 0 {
+            t.Fatalf("%s: unexpected errors %v", target, params(c).errs)
+        }
+        and, _ := filter["$and"].([]bson.M)
+        if len(and) != 1 {
+            t.Fatalf("%s: filter %v", target, filter)
+        }
+        ors, _ := and[0]["$or"].([]bson.M)
+        if len(ors) != 2 {
+            t.Fatalf("%s: filter %v", target, filter)
+        }
+        for _, or := range ors {
+            for path, cond := range or {
+                if got := cond.(bson.M)["$regex"]; got != want {
+                    t.Errorf("%s: %s $regex = %q, want %q", target, path, got, want)
+                }
+            }
+        }
+    }
+}
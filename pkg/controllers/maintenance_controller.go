@@ -42,6 +42,21 @@ func (mc *MaintenanceController) BackfillTimestamps(c *gin.Context) {
     c.JSON(http.StatusOK, out)
 }
 
+// BackfillSortKeys indexes and recomputes the stored names that sort=species
+// on animals and sort=category on species order by, for documents written
+// before they were kept or changed behind the API's back.
+func (mc *MaintenanceController) BackfillSortKeys(c *gin.Context) {
+    if err := db.BackfillSortKeys(db.Ctx, mc.DB); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    keys := make([]string, 0, len(db.SortKeys))
+    for _, r := range db.SortKeys {
+        keys = append(keys, r.From+"."+r.Key)
+    }
+    c.JSON(http.StatusOK, gin.H{"backfilled": keys})
+}
+
 func mongoPipelineForBackfill() bson.A {
     return bson.A{
         bson.D{{Key: "$set", Value: bson.M{
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "go-api/pkg/middleware"
+    "go-api/pkg/query"
+)
+
+const queryParamsKey = "queryParams"
+
+// queryParams reads the query parameters of a list request. Invalid values
+// are collected instead of returned one at a time, so that a single 400 can
+// list every bad parameter; the handler checks invalidParams once all
+// parameters are parsed.
+//
+// In lenient mode (middleware.LenientQuery) invalid values of the plain
+// parameters (page, limit, sort, order, numbers, booleans, dates) are
+// ignored and their defaults apply, as they were before validation.
+// Malformed filter expressions, fields, facets and cursors are always
+// rejected.
+type queryParams struct {
+    c       *gin.Context
+    lenient bool
+    errs    paramErrors
+}
+
+// params returns the parameter reader of the request, shared by all parsers
+// run for it.
+func params(c *gin.Context) *queryParams {
+    if q, ok := c.Get(queryParamsKey); ok {
+        return q.(*queryParams)
+    }
+    q := &queryParams{c: c, lenient: middleware.IsLenientQuery(c)}
+    c.Set(queryParamsKey, q)
+    return q
+}
+
+// invalidParams writes a 400 listing the invalid parameters, if there are
+// any, and reports whether it did.
+func invalidParams(c *gin.Context) bool {
+    q := params(c)
+    if len(q.errs) == 0 {
+        return false
+    }
+    c.JSON(http.StatusBadRequest, gin.H{"error": q.errs.Error(), "params": q.errs})
+    return true
+}
+
+// invalid records a bad value of a plain parameter; lenient mode drops it.
+func (q *queryParams) invalid(name, msg string) {
+    if !q.lenient {
+        q.reject(name, msg)
+    }
+}
+
+// reject records a bad parameter regardless of the mode.
+func (q *queryParams) reject(name, msg string) {
+    q.errs = append(q.errs, paramError{Param: name, Value: q.c.Query(name), Msg: msg})
+}
+
+// rejectErr records err for the parameter name, keeping the position of
+// filter expression errors.
+func (q *queryParams) rejectErr(name string, err error) {
+    pe := paramError{Param: name, Value: q.c.Query(name), Msg: err.Error()}
+    var se *query.SyntaxError
+    var fe *query.FieldError
+    switch {
+    case errors.As(err, &se):
+        pe.Msg, pe.Position = se.Msg, &se.Pos
+    case errors.As(err, &fe):
+        pe.Msg, pe.Position = fe.Msg, &fe.Pos
+    }
+    q.errs = append(q.errs, pe)
+}
+
+// Int returns an integer parameter within [min, max]; ok is false when it
+// is absent or invalid.
+func (q *queryParams) Int(name string, min, max int) (int, bool) {
+    raw := strings.TrimSpace(q.c.Query(name))
+    if raw == "" {
+        return 0, false
+    }
+    v, err := strconv.Atoi(raw)
+    if err != nil {
+        q.invalid(name, "must be an integer")
+        return 0, false
+    }
+    if v < min || v > max {
+        q.invalid(name, fmt.Sprintf("must be between %d and %d", min, max))
+        return 0, false
+    }
+    return v, true
+}
+
+// Float returns a number parameter within [min, max]; ok is false when it
+// is absent or invalid.
+func (q *queryParams) Float(name string, min, max float64) (float64, bool) {
+    raw := strings.TrimSpace(q.c.Query(name))
+    if raw == "" {
+        return 0, false
+    }
+    v, err := strconv.ParseFloat(raw, 64)
+    if err != nil {
+        q.invalid(name, "must be a number")
+        return 0, false
+    }
+    if v < min || v > max {
+        q.invalid(name, fmt.Sprintf("must be between %g and %g", min, max))
+        return 0, false
+    }
+    return v, true
+}
+
+// Bool returns a true/false parameter; ok is false when it is absent or
+// invalid.
+func (q *queryParams) Bool(name string) (bool, bool) {
+    raw := strings.TrimSpace(q.c.Query(name))
+    if raw == "" {
+        return false, false
+    }
+    v, err := strconv.ParseBool(raw)
+    if err != nil {
+        q.invalid(name, "must be true or false")
+        return false, false
+    }
+    return v, true
+}
+
+// Time returns an RFC 3339 or YYYY-MM-DD parameter; ok is false when it is
+// absent or invalid.
+func (q *queryParams) Time(name string) (time.Time, bool) {
+    raw := strings.TrimSpace(q.c.Query(name))
+    if raw == "" {
+        return time.Time{}, false
+    }
+    t, err := query.ParseTime(raw)
+    if err != nil {
+        q.invalid(name, "must be an RFC 3339 time or YYYY-MM-DD date")
+        return time.Time{}, false
+    }
+    return t, true
+}
+
+// Enum returns the allowed value matching the parameter (ignoring case),
+// else def.
+func (q *queryParams) Enum(name, def string, allowed ...string) string {
+    raw := strings.TrimSpace(q.c.Query(name))
+    if raw == "" {
+        return def
+    }
+    for _, a := range allowed {
+        if strings.EqualFold(raw, a) {
+            return a
+        }
+    }
+    q.invalid(name, "must be one of "+strings.Join(allowed, ", "))
+    return def
+}
+
+// paramError is an invalid query parameter.
+type paramError struct {
+    Param    string `json:"param"`
+    Value    string `json:"value"`
+    Msg      string `json:"message"`
+    Position *int   `json:"position,omitempty"`
+}
+
+func (e *paramError) Error() string {
+    return fmt.Sprintf("%s: %s", e.Param, e.Msg)
+}
+
+type paramErrors []paramError
+
+func (errs paramErrors) Error() string {
+    msgs := make([]string, len(errs))
+    for i := range errs {
+        msgs[i] = errs[i].Error()
+    }
+    return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "io"
+    "reflect"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+    "go-api/pkg/utils"
+)
+
+const jsonPatchContentType = "application/json-patch+json"
+
+// Fields the server maintains; patches may not change them.
+var readOnlyFields = map[string]bool{"_id": true, "createdAt": true, "updatedAt": true, "version": true}
+
+// decodePatch applies the request body to current, rendered as JSON, and
+// decodes the result into out. Bodies sent as application/json-patch+json are
+// RFC 6902 JSON Patches; anything else is treated as an RFC 7396 merge patch.
+func decodePatch(c *gin.Context, current, out interface{}) error {
+    body, err := io.ReadAll(c.Request.Body)
+    if err != nil {
+        return err
+    }
+    return applyPatch(current, body, c.ContentType() == jsonPatchContentType, out)
+}
+
+// applyPatch applies a merge patch, or a JSON Patch when jsonPatch is set, to
+// current and decodes the result into out, rejecting unknown fields.
+func applyPatch(current interface{}, patch []byte, jsonPatch bool, out interface{}) error {
+    if len(bytes.TrimSpace(patch)) == 0 {
+        return errors.New("empty patch")
+    }
+    doc, err := json.Marshal(current)
+    if err != nil {
+        return err
+    }
+    var patched []byte
+    if jsonPatch {
+        patched, err = utils.JSONPatch(doc, patch)
+    } else {
+        patched, err = utils.MergePatch(doc, patch)
+    }
+    if err != nil {
+        return err
+    }
+    dec := json.NewDecoder(bytes.NewReader(patched))
+    dec.DisallowUnknownFields()
+    return dec.Decode(out)
+}
+
+// patchUpdate compares two versions of a model and returns the $set and $unset
+// needed to turn current into next. Read-only fields are never touched.
+func patchUpdate(current, next interface{}) (bson.M, bson.M, error) {
+    from, err := toDoc(current)
+    if err != nil {
+        return nil, nil, err
+    }
+    to, err := toDoc(next)
+    if err != nil {
+        return nil, nil, err
+    }
+    set, unset := bson.M{}, bson.M{}
+    for k, v := range to {
+        if !readOnlyFields[k] && !reflect.DeepEqual(from[k], v) {
+            set[k] = v
+        }
+    }
+    for k := range from {
+        if _, ok := to[k]; !ok && !readOnlyFields[k] {
+            unset[k] = ""
+        }
+    }
+    return set, unset, nil
+}
+
+// saveReplace replaces the document read as raw with next, provided it has not
+// changed since, and records it in the audit log. It returns false after it
+// has written an error response.
+func saveReplace(c *gin.Context, coll *mongo.Collection, audit *AuditLog, collection string, oid primitive.ObjectID, raw bson.M, next interface{}) bool {
+    after, err := toDoc(next)
+    if err != nil {
+        utils.ServerError(c, err)
+        return false
+    }
+    res := coll.FindOneAndReplace(db.Ctx, currentVersionFilter(oid, docVersion(raw)), next, options.FindOneAndReplace().SetReturnDocument(options.Before))
+    var before bson.M
+    if err := res.Decode(&before); err != nil {
+        if errors.Is(err, mongo.ErrNoDocuments) {
+            writeMiss(c, coll, oid)
+            return false
+        }
+        utils.ServerError(c, err)
+        return false
+    }
+    audit.Record(c, collection, auditUpdate, oid, before, after)
+    return true
+}
+
+// legacyPutRequested reports whether the client asked for the old
+// partial-update PUT via the X-Legacy-Put header.
+func legacyPutRequested(c *gin.Context) bool {
+    v, _ := strconv.ParseBool(c.GetHeader("X-Legacy-Put"))
+    return v
+}
+
+// savePatch writes a patch computed from raw, provided the document has not
+// changed since it was read, and records it in the audit log. It returns the
+// updated document, or false after it has written an error response.
+func savePatch(c *gin.Context, coll *mongo.Collection, audit *AuditLog, collection string, oid primitive.ObjectID, raw, set, unset bson.M) (bson.M, bool) {
+    if len(set) == 0 && len(unset) == 0 {
+        return raw, true
+    }
+    set["updatedAt"] = time.Now().UTC()
+    update := bson.M{"$set": set, "$inc": bson.M{"version": int64(1)}}
+    if len(unset) > 0 {
+        update["$unset"] = unset
+    }
+    res := coll.FindOneAndUpdate(db.Ctx, currentVersionFilter(oid, docVersion(raw)), update, options.FindOneAndUpdate().SetReturnDocument(options.Before))
+    var before bson.M
+    if err := res.Decode(&before); err != nil {
+        if errors.Is(err, mongo.ErrNoDocuments) {
+            writeMiss(c, coll, oid)
+            return nil, false
+        }
+        utils.ServerError(c, err)
+        return nil, false
+    }
+    after, err := applyUpdate(before, set, unset)
+    if err != nil {
+        utils.ServerError(c, err)
+        return nil, false
+    }
+    audit.Record(c, collection, auditUpdate, oid, before, after)
+    return after, true
+}
+
+// loadForWrite reads the document to patch or replace and checks If-Match
+// against it. It returns false after it has written an error response.
+func loadForWrite(c *gin.Context, coll *mongo.Collection) (primitive.ObjectID, bson.M, bool) {
+    oid, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        utils.BadRequest(c, errors.New("invalid id"))
+        return oid, nil, false
+    }
+    var raw bson.M
+    if err := coll.FindOne(db.Ctx, bson.M{"_id": oid}).Decode(&raw); err != nil {
+        if errors.Is(err, mongo.ErrNoDocuments) {
+            utils.NotFound(c)
+            return oid, nil, false
+        }
+        utils.ServerError(c, err)
+        return oid, nil, false
+    }
+    ok, err := ifMatchOK(c, docVersion(raw))
+    if err != nil {
+        utils.BadRequest(c, err)
+        return oid, nil, false
+    }
+    if !ok {
+        utils.PreconditionFailed(c)
+        return oid, nil, false
+    }
+    return oid, raw, true
+}
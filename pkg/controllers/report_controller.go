@@ -0,0 +1,279 @@
+package controllers
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+    "go-api/pkg/query"
+    "go-api/pkg/utils"
+)
+
+// maxReportBuckets bounds the length of a time series.
+const maxReportBuckets = 1000
+
+// reportMetrics maps each metric to the date it counts. Legacy documents
+// without createdAt fall back to their ObjectID timestamp; adoptions made
+// before adoptedAt was recorded fall back to the last update.
+var reportMetrics = map[string]struct {
+    match bson.M
+    date  interface{}
+}{
+    "intakes": {
+        match: bson.M{},
+        date:  bson.M{"$ifNull": bson.A{"$createdAt", bson.M{"$toDate": "$_id"}}},
+    },
+    "adoptions": {
+        match: bson.M{"adopted": true},
+        date:  bson.M{"$ifNull": bson.A{"$adoptedAt", "$updatedAt", "$createdAt", bson.M{"$toDate": "$_id"}}},
+    },
+}
+
+type ReportController struct {
+    DB *mongo.Database
+}
+
+func NewReportController(client *mongo.Client, dbName string) *ReportController {
+    return &ReportController{DB: client.Database(dbName)}
+}
+
+type timeBucket struct {
+    Start time.Time `json:"start"`
+    Count int       `json:"count"`
+}
+
+// Timeseries godoc
+// @Summary Intakes or adoptions per day, week or month
+// @Description Buckets are zero-filled. from is inclusive, to is exclusive (a YYYY-MM-DD to includes that day).
+// @Description Weeks start on Monday. species and category accept ids or names (comma-separated).
+// @Tags reports
+// @Produce json
+// @Param metric query string true "intakes or adoptions"
+// @Param interval query string false "day, week or month (default month)"
+// @Param from query string false "Start (RFC3339 or YYYY-MM-DD); default 12 intervals before to"
+// @Param to query string false "End (RFC3339 or YYYY-MM-DD); default now"
+// @Param tz query string false "IANA time zone for bucket boundaries (default UTC)"
+// @Param species query string false "Species ids or names"
+// @Param category query string false "Category ids or names"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /reports/timeseries [get]
+func (rc *ReportController) Timeseries(c *gin.Context) {
+    metricName := c.Query("metric")
+    metric, ok := reportMetrics[metricName]
+    if !ok {
+        utils.BadRequest(c, errors.New("metric must be intakes or adoptions"))
+        return
+    }
+    interval := c.DefaultQuery("interval", "month")
+    if interval != "day" && interval != "week" && interval != "month" {
+        utils.BadRequest(c, errors.New("interval must be day, week or month"))
+        return
+    }
+    loc := time.UTC
+    if tz := c.Query("tz"); tz != "" {
+        l, err := time.LoadLocation(tz)
+        if err != nil {
+            utils.BadRequest(c, fmt.Errorf("unknown time zone %q", tz))
+            return
+        }
+        loc = l
+    }
+    to := time.Now().In(loc)
+    if v := c.Query("to"); v != "" {
+        t, err := parseReportTime(v, loc, true)
+        if err != nil {
+            utils.BadRequest(c, fmt.Errorf("to: %w", err))
+            return
+        }
+        to = t
+    }
+    from := stepBucket(truncBucket(to, interval), interval, -11)
+    if v := c.Query("from"); v != "" {
+        t, err := parseReportTime(v, loc, false)
+        if err != nil {
+            utils.BadRequest(c, fmt.Errorf("from: %w", err))
+            return
+        }
+        from = t
+    }
+    if !from.Before(to) {
+        utils.BadRequest(c, errors.New("from must be before to"))
+        return
+    }
+    var starts []time.Time
+    for b := truncBucket(from, interval); b.Before(to); b = stepBucket(b, interval, 1) {
+        if len(starts) == maxReportBuckets {
+            utils.BadRequest(c, fmt.Errorf("range spans more than %d %ss", maxReportBuckets, interval))
+            return
+        }
+        starts = append(starts, b)
+    }
+
+    match := bson.M{}
+    for k, v := range metric.match {
+        match[k] = v
+    }
+    speciesRefs, err := rc.speciesRefs(c.Query("species"), c.Query("category"))
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    if speciesRefs != nil {
+        match["species"] = bson.M{"$in": speciesRefs}
+    }
+
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: match}},
+        {{Key: "$set", Value: bson.M{"_date": metric.date}}},
+        {{Key: "$match", Value: bson.M{"_date": bson.M{"$gte": from, "$lt": to}}}},
+        {{Key: "$group", Value: bson.M{
+            "_id": bson.M{"$dateTrunc": bson.M{
+                "date":        "$_date",
+                "unit":        interval,
+                "timezone":    loc.String(),
+                "startOfWeek": "monday",
+            }},
+            "n": bson.M{"$sum": 1},
+        }}},
+    }
+    cur, err := rc.DB.Collection("animals").Aggregate(c.Request.Context(), pipeline)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    var rows []struct {
+        Start time.Time `bson:"_id"`
+        N     int       `bson:"n"`
+    }
+    if err := cur.All(c.Request.Context(), &rows); err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    counts := map[int64]int{}
+    for _, r := range rows {
+        counts[r.Start.Unix()] = r.N
+    }
+    buckets := make([]timeBucket, len(starts))
+    total := 0
+    for i, s := range starts {
+        buckets[i] = timeBucket{Start: s, Count: counts[s.Unix()]}
+        total += buckets[i].Count
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "metric":   metricName,
+        "interval": interval,
+        "from":     from,
+        "to":       to,
+        "timezone": loc.String(),
+        "total":    total,
+        "buckets":  buckets,
+    })
+}
+
+// speciesRefs returns the species references (hex strings, ObjectIDs and
+// names) selected by the species and category parameters, or nil when
+// neither is given.
+func (rc *ReportController) speciesRefs(species, category string) (bson.A, error) {
+    if species == "" && category == "" {
+        return nil, nil
+    }
+    speciesColl := rc.DB.Collection("species")
+    refs, err := refList(species, nameResolver(speciesColl, "name", "species_name"))
+    if err != nil {
+        return nil, err
+    }
+    if category != "" {
+        cats, err := refList(category, nameResolver(rc.DB.Collection("categories"), "name", "category_name"))
+        if err != nil {
+            return nil, err
+        }
+        cur, err := speciesColl.Find(db.Ctx, bson.M{"category": bson.M{"$in": cats}}, options.Find().SetProjection(bson.M{"name": 1, "species_name": 1}))
+        if err != nil {
+            return nil, err
+        }
+        var docs []bson.M
+        if err := cur.All(db.Ctx, &docs); err != nil {
+            return nil, err
+        }
+        inCategory := bson.A{}
+        for _, d := range docs {
+            s, err := mapSpecies(d)
+            if err != nil {
+                return nil, err
+            }
+            inCategory = append(inCategory, s.ID, s.ID.Hex())
+            if s.Name != "" {
+                inCategory = append(inCategory, s.Name)
+            }
+        }
+        if species != "" {
+            // Both given: species must be in the category too.
+            refs = intersectRefs(refs, inCategory)
+        } else {
+            refs = inCategory
+        }
+    }
+    return refs, nil
+}
+
+func intersectRefs(a, b bson.A) bson.A {
+    keep := map[interface{}]bool{}
+    for _, v := range b {
+        keep[v] = true
+    }
+    out := bson.A{}
+    for _, v := range a {
+        if keep[v] {
+            out = append(out, v)
+        }
+    }
+    return out
+}
+
+// parseReportTime parses a report bound in loc. A date-only upper bound
+// covers the whole day, so it is moved to the next midnight.
+func parseReportTime(v string, loc *time.Location, upper bool) (time.Time, error) {
+    if t, err := time.ParseInLocation("2006-01-02", v, loc); err == nil {
+        if upper {
+            t = t.AddDate(0, 0, 1)
+        }
+        return t, nil
+    }
+    t, err := query.ParseTime(v)
+    if err != nil {
+        return time.Time{}, err
+    }
+    return t.In(loc), nil
+}
+
+// truncBucket returns the start of the bucket containing t, in t's location.
+func truncBucket(t time.Time, interval string) time.Time {
+    y, m, d := t.Date()
+    switch interval {
+    case "month":
+        return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+    case "week":
+        day := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+        // Weeks start on Monday.
+        return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+    }
+    return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func stepBucket(t time.Time, interval string, n int) time.Time {
+    switch interval {
+    case "month":
+        return t.AddDate(0, n, 0)
+    case "week":
+        return t.AddDate(0, 0, 7*n)
+    }
+    return t.AddDate(0, 0, n)
+}
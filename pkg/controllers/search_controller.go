@@ -0,0 +1,313 @@
+package controllers
+
+import (
+    "errors"
+    "html"
+    "log"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "unicode"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+    "go-api/pkg/models"
+    "go-api/pkg/utils"
+)
+
+// Highlighted matches are wrapped in these markers.
+const (
+    highlightPre  = "<em>"
+    highlightPost = "</em>"
+)
+
+// searchTarget describes how one collection takes part in GET /search.
+type searchTarget struct {
+    typ        string // hit type reported to clients
+    collection string
+    // weights of the text-indexed fields; their names are also the fields highlighted.
+    weights bson.D
+    item    func(raw bson.M) (interface{}, error)
+}
+
+var searchTargets = []searchTarget{
+    {
+        typ:        "animal",
+        collection: "animals",
+        weights:    bson.D{{Key: "name", Value: 10}, {Key: "animal_name", Value: 10}},
+        item:       func(raw bson.M) (interface{}, error) { return mapAnimal(raw) },
+    },
+    {
+        typ:        "species",
+        collection: "species",
+        weights:    bson.D{{Key: "name", Value: 10}, {Key: "species_name", Value: 10}},
+        item:       func(raw bson.M) (interface{}, error) { return mapSpecies(raw) },
+    },
+    {
+        typ:        "category",
+        collection: "categories",
+        weights:    bson.D{{Key: "name", Value: 10}, {Key: "category_name", Value: 10}},
+        item:       func(raw bson.M) (interface{}, error) { return mapCategory(raw) },
+    },
+}
+
+type SearchController struct {
+    DB      *mongo.Database
+    indexes sync.Once
+}
+
+func NewSearchController(client *mongo.Client, dbName string) *SearchController {
+    return &SearchController{DB: client.Database(dbName)}
+}
+
+// searchHit is one result of GET /search.
+type searchHit struct {
+    Type       string            `json:"type"`
+    ID         string            `json:"id"`
+    Name       string            `json:"name"`
+    Score      float64           `json:"score"`
+    Highlights map[string]string `json:"highlights,omitempty"`
+    Item       interface{}       `json:"item"`
+}
+
+// ensureIndexes creates the text index of each searched collection once per
+// process. A collection can have only one text index, so a conflicting
+// existing one is left alone and used as is.
+func (sc *SearchController) ensureIndexes() {
+    sc.indexes.Do(func() {
+        for _, t := range searchTargets {
+            keys := bson.D{}
+            for _, w := range t.weights {
+                keys = append(keys, bson.E{Key: w.Key, Value: "text"})
+            }
+            _, err := sc.DB.Collection(t.collection).Indexes().CreateOne(db.Ctx, mongo.IndexModel{
+                Keys:    keys,
+                Options: options.Index().SetName("search_text").SetWeights(t.weights),
+            })
+            if err != nil {
+                log.Printf("search: text index on %s: %v", t.collection, err)
+            }
+        }
+    })
+}
+
+// Search godoc
+// @Summary Full-text search across animals, species and categories
+// @Description Uses MongoDB text indexes (stemming, "quoted phrases", -negation). Hits from all
+// @Description collections are merged and ranked by text score; matched words are wrapped in <em></em>.
+// @Tags search
+// @Produce json
+// @Param q query string true "Search text"
+// @Param types query string false "Comma-separated hit types: animal, species, category (default all)"
+// @Param limit query int false "Maximum number of hits (default 20, max 100)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /search [get]
+func (sc *SearchController) Search(c *gin.Context) {
+    q := strings.TrimSpace(c.Query("q"))
+    if q == "" {
+        utils.BadRequest(c, errors.New("q is required"))
+        return
+    }
+    limit := 20
+    if v := c.Query("limit"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 1 || n > 100 {
+            utils.BadRequest(c, errors.New("limit must be between 1 and 100"))
+            return
+        }
+        limit = n
+    }
+    targets := searchTargets
+    if v := c.Query("types"); v != "" {
+        selected, err := selectSearchTargets(v)
+        if err != nil {
+            utils.BadRequest(c, err)
+            return
+        }
+        targets = selected
+    }
+    sc.ensureIndexes()
+
+    // Each collection returns at most limit hits; the merged list is cut to limit.
+    results := make([][]searchHit, len(targets))
+    errs := make([]error, len(targets))
+    var wg sync.WaitGroup
+    for i, t := range targets {
+        wg.Add(1)
+        go func(i int, t searchTarget) {
+            defer wg.Done()
+            results[i], errs[i] = sc.searchOne(c, t, q, limit)
+        }(i, t)
+    }
+    wg.Wait()
+    for _, err := range errs {
+        if err != nil {
+            utils.ServerError(c, err)
+            return
+        }
+    }
+
+    hits := []searchHit{}
+    counts := gin.H{}
+    for i, t := range targets {
+        hits = append(hits, results[i]...)
+        counts[t.typ] = len(results[i])
+    }
+    sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+    if len(hits) > limit {
+        hits = hits[:limit]
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "query":  q,
+        "items":  hits,
+        "counts": counts,
+    })
+}
+
+func (sc *SearchController) searchOne(c *gin.Context, t searchTarget, q string, limit int) ([]searchHit, error) {
+    ctx := c.Request.Context()
+    score := bson.M{"$meta": "textScore"}
+    opts := options.Find().
+        SetProjection(bson.M{"score": score}).
+        SetSort(bson.D{{Key: "score", Value: score}}).
+        SetLimit(int64(limit))
+    cur, err := sc.DB.Collection(t.collection).Find(ctx, bson.M{"$text": bson.M{"$search": q}}, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cur.Close(ctx)
+
+    terms := searchTerms(q)
+    var hits []searchHit
+    for cur.Next(ctx) {
+        var raw bson.M
+        if err := cur.Decode(&raw); err != nil {
+            return nil, err
+        }
+        item, err := t.item(raw)
+        if err != nil {
+            skipUndecodable(t.collection, raw["_id"], err)
+            continue
+        }
+        hit := searchHit{Type: t.typ, Item: item}
+        hit.Score, _ = raw["score"].(float64)
+        hit.ID, hit.Name = hitIdentity(hit.Item)
+        for _, w := range t.weights {
+            s, ok := raw[w.Key].(string)
+            if !ok {
+                continue
+            }
+            if marked, found := highlight(s, terms); found {
+                if hit.Highlights == nil {
+                    hit.Highlights = map[string]string{}
+                }
+                hit.Highlights[w.Key] = marked
+            }
+        }
+        hits = append(hits, hit)
+    }
+    return hits, cur.Err()
+}
+
+func selectSearchTargets(types string) ([]searchTarget, error) {
+    var out []searchTarget
+    for _, name := range strings.Split(types, ",") {
+        name = strings.TrimSpace(name)
+        found := false
+        for _, t := range searchTargets {
+            if t.typ == name || t.collection == name {
+                out = append(out, t)
+                found = true
+                break
+            }
+        }
+        if !found {
+            return nil, errors.New("unknown type " + strconv.Quote(name) + " (animal, species, category)")
+        }
+    }
+    return out, nil
+}
+
+func hitIdentity(item interface{}) (id, name string) {
+    switch v := item.(type) {
+    case models.Animal:
+        return v.ID.Hex(), v.Name
+    case models.Species:
+        return v.ID.Hex(), v.Name
+    case models.Category:
+        return v.ID.Hex(), v.Name
+    }
+    return "", ""
+}
+
+// searchTerms extracts the positive words of a $text query: quoted phrases
+// are split into their words and negated words (-word) are dropped.
+func searchTerms(q string) []string {
+    var terms []string
+    for _, f := range strings.Fields(strings.ReplaceAll(q, `"`, " ")) {
+        if strings.HasPrefix(f, "-") {
+            continue
+        }
+        for _, w := range strings.FieldsFunc(f, isWordSeparator) {
+            terms = append(terms, strings.ToLower(w))
+        }
+    }
+    return terms
+}
+
+func isWordSeparator(r rune) bool {
+    return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// highlight wraps the words of s that match a term. Text search stems words,
+// so "cats" finds "cat": a word matches when it equals a term or one is a
+// short extension of the other. The result is HTML: the stored text is
+// escaped, so only the highlight tags are markup.
+func highlight(s string, terms []string) (string, bool) {
+    var b strings.Builder
+    found := false
+    runes := []rune(s)
+    for i := 0; i < len(runes); {
+        if isWordSeparator(runes[i]) {
+            b.WriteString(html.EscapeString(string(runes[i])))
+            i++
+            continue
+        }
+        j := i
+        for j < len(runes) && !isWordSeparator(runes[j]) {
+            j++
+        }
+        word := string(runes[i:j])
+        if matchesTerm(strings.ToLower(word), terms) {
+            b.WriteString(highlightPre + html.EscapeString(word) + highlightPost)
+            found = true
+        } else {
+            b.WriteString(html.EscapeString(word))
+        }
+        i = j
+    }
+    return b.String(), found
+}
+
+func matchesTerm(word string, terms []string) bool {
+    for _, t := range terms {
+        if word == t {
+            return true
+        }
+        short, long := t, word
+        if len(short) > len(long) {
+            short, long = long, short
+        }
+        if len([]rune(short)) >= 3 && strings.HasPrefix(long, short) && len([]rune(long))-len([]rune(short)) <= 3 {
+            return true
+        }
+    }
+    return false
+}
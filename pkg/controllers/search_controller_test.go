@@ -0,0 +1,24 @@
+package controllers
+
+import "testing"
+
+func TestHighlightEscapesStoredText(t *testing.T) {
+    tests := []struct {
+        text  string
+        terms []string
+        want  string
+        found bool
+    }{
+        {"Black Jack", []string{"black"}, "<em>Black</em> Jack", true},
+        {"cat", []string{"cats"}, "<em>cat</em>", true},
+        {`<img src=x onerror=alert(1)> Rex`, []string{"rex"}, `&lt;img src=x onerror=alert(1)&gt; <em>Rex</em>`, true},
+        {`<script>`, []string{"script"}, `&lt;<em>script</em>&gt;`, true},
+        {`Tom & "Jerry"`, []string{"dog"}, `Tom &amp; &#34;Jerry&#34;`, false},
+    }
+    for _, tt := range tests {
+        got, found := highlight(tt.text, tt.terms)
+        if got != tt.want || found != tt.found {
+            t.Errorf("highlight(%q, %q) = %q, %v; want %q, %v", tt.text, tt.terms, got, found, tt.want, tt.found)
+        }
+    }
+}
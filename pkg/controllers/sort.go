@@ -0,0 +1,183 @@
+package controllers
+
+import (
+    "log"
+    "math"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+)
+
+// listCollation is db.TextCollation. It applies to the whole list query
+// whenever a text key is sorted on, so that keyset cursors compare the way
+// the sort does.
+var listCollation = db.TextCollation
+
+// earthRadius is the mean Earth radius in meters.
+const earthRadius = 6371008.8
+
+// sortKey is an accepted sort= value.
+type sortKey struct {
+    // Path is the document path sorted on, or the field Derived sets.
+    Path string
+    // Text keys are compared with listCollation.
+    Text bool
+    // Derived, for keys that are not stored, returns the aggregation stages
+    // setting Path. It may record invalid parameters and return nil.
+    Derived func(c *gin.Context) bson.A
+}
+
+// listSort is the parsed sort of a list request.
+type listSort struct {
+    Keys bson.D
+    // Stages compute the derived keys; they run before sorting.
+    Stages    bson.A
+    Collation *options.Collation
+}
+
+// Sort parses sort= into a listSort. sort takes comma-separated keys, each
+// ascending or, prefixed with -, descending: sort=-adopted,name,-age. Without
+// sort= the list is in descending DefaultSort order. The older form
+// sort=name&order=asc|desc still applies when order= is given: it sets the
+// direction of a single unprefixed key, or of the default sort.
+func (s *listSpec) Sort(c *gin.Context) listSort {
+    q := params(c)
+    raw := strings.TrimSpace(c.Query("sort"))
+    parts := splitList(raw)
+    defaulted := len(parts) == 0
+    if defaulted {
+        parts = []string{s.DefaultSort}
+    }
+    legacy := len(parts) == 1 && !strings.HasPrefix(parts[0], "-")
+    order := "asc"
+    if defaulted {
+        order = "desc"
+    }
+    if c.Query("order") != "" {
+        if legacy {
+            order = q.Enum("order", order, "asc", "desc")
+        } else {
+            q.invalid("order", "only applies to a single sort key without a - prefix")
+        }
+    }
+
+    var ls listSort
+    seen := map[string]bool{}
+    for _, part := range parts {
+        dir := int32(1)
+        name := part
+        switch {
+        case legacy && order == "desc":
+            dir = -1
+        case strings.HasPrefix(part, "-"):
+            dir, name = -1, part[1:]
+        }
+        key, ok := s.Sorts[name]
+        if !ok {
+            q.invalid("sort", "unknown sort key "+strconv.Quote(name)+"; allowed: "+strings.Join(s.sortNames(), ", "))
+            continue
+        }
+        if seen[name] {
+            q.invalid("sort", "duplicate sort key "+strconv.Quote(name))
+            continue
+        }
+        seen[name] = true
+        if key.Derived != nil {
+            ls.Stages = append(ls.Stages, key.Derived(c)...)
+        }
+        if key.Text {
+            ls.Collation = listCollation
+        }
+        ls.Keys = append(ls.Keys, bson.E{Key: key.Path, Value: dir})
+    }
+    if len(ls.Keys) == 0 {
+        // lenient mode: fall back to the default order
+        ls.Keys = bson.D{{Key: s.Sorts[s.DefaultSort].Path, Value: int32(-1)}}
+    }
+    return ls
+}
+
+func (s *listSpec) sortNames() []string {
+    names := make([]string, 0, len(s.Sorts))
+    for n := range s.Sorts {
+        names = append(names, n)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// refSortKey sorts on the referenced name that r keeps on each document.
+func refSortKey(r db.RefSort) sortKey {
+    return sortKey{Path: r.Key, Text: true}
+}
+
+// refreshSortKeys recomputes r's key on the written documents ids of coll.
+// The write has succeeded by then, so a failure is only logged; the
+// maintenance backfill repairs it.
+func refreshSortKeys(coll *mongo.Collection, r db.RefSort, ids ...interface{}) {
+    if err := r.RefreshIDs(db.Ctx, coll.Database(), ids...); err != nil {
+        log.Printf("sort keys: refreshing %s.%s: %v", r.From, r.Key, err)
+    }
+}
+
+// refreshReferrers recomputes r's key on the documents referencing oid, after
+// the referenced document in coll was renamed or deleted.
+func refreshReferrers(coll *mongo.Collection, r db.RefSort, oid primitive.ObjectID) {
+    filter := bson.M{r.Field: bson.M{"$in": bson.A{oid, oid.Hex()}}}
+    if err := r.Refresh(db.Ctx, coll.Database(), filter); err != nil {
+        log.Printf("sort keys: refreshing %s.%s for %s: %v", r.From, r.Key, oid.Hex(), err)
+    }
+}
+
+// distanceKey sorts on the great-circle distance in meters between the
+// location at path and the point given by the lat and lng parameters.
+// Documents without a location sort as infinitely far.
+func distanceKey(path string) sortKey {
+    return sortKey{
+        Path: "_distance",
+        Derived: func(c *gin.Context) bson.A {
+            q := params(c)
+            lat, okLat := q.Float("lat", -90, 90)
+            lng, okLng := q.Float("lng", -180, 180)
+            if !okLat || !okLng {
+                if c.Query("lat") == "" || c.Query("lng") == "" {
+                    q.reject("sort", "sorting by distance needs lat and lng")
+                }
+                return nil
+            }
+            distance := bson.M{"$cond": bson.A{
+                bson.M{"$isArray": "$" + path + ".coordinates"},
+                distanceExpr(path, lat, lng),
+                math.Inf(1),
+            }}
+            return bson.A{bson.M{"$set": bson.M{"_distance": distance}}}
+        },
+    }
+}
+
+// distanceExpr is the haversine distance in meters from the GeoJSON point
+// at path to (lat, lng).
+func distanceExpr(path string, lat, lng float64) bson.M {
+    rad := func(v interface{}) bson.M { return bson.M{"$degreesToRadians": v} }
+    sq := func(v interface{}) bson.M { return bson.M{"$pow": bson.A{v, 2}} }
+    half := func(v interface{}) bson.M { return bson.M{"$divide": bson.A{v, 2}} }
+    pLng := bson.M{"$arrayElemAt": bson.A{"$" + path + ".coordinates", 0}}
+    pLat := bson.M{"$arrayElemAt": bson.A{"$" + path + ".coordinates", 1}}
+    dLat := bson.M{"$subtract": bson.A{rad(pLat), lat * math.Pi / 180}}
+    dLng := bson.M{"$subtract": bson.A{rad(pLng), lng * math.Pi / 180}}
+    a := bson.M{"$add": bson.A{
+        sq(bson.M{"$sin": half(dLat)}),
+        bson.M{"$multiply": bson.A{math.Cos(lat * math.Pi / 180), bson.M{"$cos": rad(pLat)}, sq(bson.M{"$sin": half(dLng)})}},
+    }}
+    // rounding can push a just above 1, where $asin fails
+    a = bson.M{"$min": bson.A{1, a}}
+    return bson.M{"$multiply": bson.A{2 * earthRadius, bson.M{"$asin": bson.M{"$sqrt": a}}}}
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+    "reflect"
+    "testing"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// paramNames lists the parameters the request was rejected for.
+func paramNames(errs paramErrors) []string {
+    var names []string
+    for _, e := range errs {
+        names = append(names, e.Param)
+    }
+    return names
+}
+
+func TestSortParsing(t *testing.T) {
+    tests := []struct {
+        target string
+        keys   bson.D
+        errs   []string
+    }{
+        {"/animals", bson.D{{Key: "createdAt", Value: int32(-1)}}, nil},
+        {"/animals?order=asc", bson.D{{Key: "createdAt", Value: int32(1)}}, nil},
+        // an unprefixed key is ascending; order= still sets its direction
+        {"/animals?sort=name", bson.D{{Key: "name", Value: int32(1)}}, nil},
+        {"/animals?sort=age", bson.D{{Key: "age", Value: int32(1)}}, nil},
+        {"/animals?sort=name&order=desc", bson.D{{Key: "name", Value: int32(-1)}}, nil},
+        {"/animals?sort=name&order=ASC", bson.D{{Key: "name", Value: int32(1)}}, nil},
+        {"/animals?sort=-age", bson.D{{Key: "age", Value: int32(-1)}}, nil},
+        {"/animals?sort=species", bson.D{{Key: "speciesSort", Value: int32(1)}}, nil},
+        {"/animals?sort=-adopted,%20name%20,-age", bson.D{
+            {Key: "adopted", Value: int32(-1)}, {Key: "name", Value: int32(1)}, {Key: "age", Value: int32(-1)},
+        }, nil},
+        {"/animals?sort=name,age&order=desc", bson.D{{Key: "name", Value: int32(1)}, {Key: "age", Value: int32(1)}}, []string{"order"}},
+        {"/animals?sort=-name&order=asc", bson.D{{Key: "name", Value: int32(-1)}}, []string{"order"}},
+        {"/animals?sort=name&order=up", bson.D{{Key: "name", Value: int32(1)}}, []string{"order"}},
+        {"/animals?sort=colour", bson.D{{Key: "createdAt", Value: int32(-1)}}, []string{"sort"}},
+        {"/animals?sort=name,-name", bson.D{{Key: "name", Value: int32(1)}}, []string{"sort"}},
+    }
+    for _, tt := range tests {
+        c := testContext(tt.target)
+        ls := animalList.Sort(c)
+        if !reflect.DeepEqual(ls.Keys, tt.keys) {
+            t.Errorf("%s: keys = %v, want %v", tt.target, ls.Keys, tt.keys)
+        }
+        if got := paramNames(params(c).errs); !reflect.DeepEqual(got, tt.errs) {
+            t.Errorf("%s: invalid params = %v, want %v", tt.target, got, tt.errs)
+        }
+    }
+}
+
+func TestSortCollation(t *testing.T) {
+    for target, text := range map[string]bool{
+        "/animals":                 false,
+        "/animals?sort=-age":       false,
+        "/animals?sort=-age,owner": true,
+        "/animals?sort=species":    true,
+    } {
+        if ls := animalList.Sort(testContext(target)); (ls.Collation != nil) != text {
+            t.Errorf("%s: collation %v, want text collation %v", target, ls.Collation, text)
+        }
+    }
+}
+
+func TestDistanceSort(t *testing.T) {
+    c := testContext("/animals?sort=-distance,name&lat=60.17&lng=24.94")
+    ls := animalList.Sort(c)
+    if errs := params(c).errs; len(errs) > 0 {
+        t.Fatalf("unexpected errors %v", errs)
+    }
+    want := bson.D{{Key: "_distance", Value: int32(-1)}, {Key: "name", Value: int32(1)}}
+    if !reflect.DeepEqual(ls.Keys, want) {
+        t.Errorf("keys = %v, want %v", ls.Keys, want)
+    }
+    if len(ls.Stages) != 1 {
+        t.Fatalf("stages = %v, want one $set", ls.Stages)
+    }
+    set, _ := ls.Stages[0].(bson.M)["$set"].(bson.M)
+    if _, ok := set["_distance"]; !ok {
+        t.Errorf("stage %v does not set _distance", ls.Stages[0])
+    }
+
+    for target, param := range map[string]string{
+        "/animals?sort=distance":                 "sort",
+        "/animals?sort=distance&lat=60.17":       "sort",
+        "/animals?sort=distance&lat=91&lng=24.9": "lat",
+    } {
+        c := testContext(target)
+        if ls := animalList.Sort(c); len(ls.Stages) != 0 {
+            t.Errorf("%s: stages %v without a point", target, ls.Stages)
+        }
+        if got := paramNames(params(c).errs); !reflect.DeepEqual(got, []string{param}) {
+            t.Errorf("%s: invalid params = %v, want [%s]", target, got, param)
+        }
+    }
+}
+
+func TestMultiKeyCursor(t *testing.T) {
+    sort := keysetSort(bson.D{{Key: "adopted", Value: int32(-1)}, {Key: "name", Value: int32(1)}})
+    if want := (bson.E{Key: "_id", Value: int32(1)}); sort[2] != want {
+        t.Fatalf("tie-breaker = %v, want %v", sort[2], want)
+    }
+    id := primitive.NewObjectID()
+    raw, _ := bson.Marshal(bson.M{"_id": id, "adopted": true, "name": "Misu", "age": 3})
+
+    tok, err := decodeCursor(encodeCursor(sort, raw), sort)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := []interface{}{true, "Misu", id}; !reflect.DeepEqual(tok.Values, want) {
+        t.Errorf("cursor values = %v, want %v", tok.Values, want)
+    }
+    other := keysetSort(bson.D{{Key: "adopted", Value: int32(-1)}, {Key: "name", Value: int32(-1)}})
+    if _, err := decodeCursor(encodeCursor(sort, raw), other); err == nil {
+        t.Error("a cursor was accepted for another sort order")
+    }
+    if _, err := decodeCursor("not a cursor", sort); err == nil {
+        t.Error("a malformed cursor was accepted")
+    }
+
+    after := keysetFilter(sort, tok.Values, false)
+    wantAfter := bson.M{"$or": []bson.M{
+        {"$or": []bson.M{{"adopted": bson.M{"$lt": true}}, {"adopted": nil}}},
+        {"adopted": true, "name": bson.M{"$gt": "Misu"}},
+        {"adopted": true, "name": "Misu", "_id": bson.M{"$gt": id}},
+    }}
+    if !reflect.DeepEqual(after, wantAfter) {
+        t.Errorf("after:\n got %v\nwant %v", after, wantAfter)
+    }
+    before := keysetFilter(sort, tok.Values, true)
+    wantBefore := bson.M{"$or": []bson.M{
+        {"adopted": bson.M{"$gt": true}},
+        {"$and": []bson.M{{"adopted": true}, {"$or": []bson.M{{"name": bson.M{"$lt": "Misu"}}, {"name": nil}}}}},
+        {"$and": []bson.M{{"adopted": true, "name": "Misu"}, {"$or": []bson.M{{"_id": bson.M{"$lt": id}}, {"_id": nil}}}}},
+    }}
+    if !reflect.DeepEqual(before, wantBefore) {
+        t.Errorf("before:\n got %v\nwant %v", before, wantBefore)
+    }
+
+    // a missing name sorts lowest: after it come the present names
+    missing, _ := bson.Marshal(bson.M{"_id": id, "adopted": false})
+    tok, err = decodeCursor(encodeCursor(sort, missing), sort)
+    if err != nil {
+        t.Fatal(err)
+    }
+    after = keysetFilter(sort, tok.Values, false)
+    wantAfter = bson.M{"$or": []bson.M{
+        {"$or": []bson.M{{"adopted": bson.M{"$lt": false}}, {"adopted": nil}}},
+        {"adopted": false, "name": bson.M{"$ne": nil}},
+        {"adopted": false, "name": nil, "_id": bson.M{"$gt": id}},
+    }}
+    if !reflect.DeepEqual(after, wantAfter) {
+        t.Errorf("after a missing value:\n got %v\nwant %v", after, wantAfter)
+    }
+}
@@ -3,7 +3,6 @@ package controllers
 import (
     "errors"
     "net/http"
-    "strconv"
     "strings"
     "time"
 
@@ -15,15 +14,20 @@ import (
 
     "go-api/pkg/db"
     "go-api/pkg/models"
+    "go-api/pkg/query"
     "go-api/pkg/utils"
 )
 
 type SpeciesController struct {
     Collection *mongo.Collection
+    Audit      *AuditLog
+    // LegacyPut makes PUT behave as a partial update for every client.
+    LegacyPut bool
 }
 
 func NewSpeciesController(client *mongo.Client, dbName string) *SpeciesController {
-    return &SpeciesController{Collection: client.Database(dbName).Collection("species")}
+    database := client.Database(dbName)
+    return &SpeciesController{Collection: database.Collection("species"), Audit: NewAuditLog(database)}
 }
 
 func (sc *SpeciesController) CreateSpecies(c *gin.Context) {
@@ -47,33 +51,46 @@ func (sc *SpeciesController) CreateSpecies(c *gin.Context) {
         utils.BadRequest(c, errors.New("name is required"))
         return
     }
-    if body.Category != "" {
-        if oid, err := primitive.ObjectIDFromHex(body.Category); err == nil {
-            m.Category = oid.Hex()
-        } else {
-            m.Category = body.Category
-        }
-    }
+    m.Category = categoryRef(body.Category)
     now := time.Now().UTC()
     m.CreatedAt = now
     m.UpdatedAt = now
+    m.Version = 1
     res, err := sc.Collection.InsertOne(db.Ctx, m)
     if err != nil {
         utils.ServerError(c, err)
         return
     }
     m.ID = res.InsertedID.(primitive.ObjectID)
+    refreshSortKeys(sc.Collection, db.CategorySort, m.ID)
+    if after, err := toDoc(m); err == nil {
+        sc.Audit.Record(c, "species", auditCreate, m.ID, nil, after)
+    }
+    setETag(c, m.Version)
     c.JSON(http.StatusCreated, m)
 }
 
+// categoryRef normalizes a category reference for storage. Every write
+// stores it as a string: the category id in lower-case hex, or, for legacy
+// data, the category name as given.
+func categoryRef(s string) string {
+    s = strings.TrimSpace(s)
+    if oid, err := primitive.ObjectIDFromHex(s); err == nil {
+        return oid.Hex()
+    }
+    return s
+}
+
 func (sc *SpeciesController) GetSpecies(c *gin.Context) {
     oid, err := primitive.ObjectIDFromHex(c.Param("id"))
     if err != nil {
         utils.BadRequest(c, errors.New("invalid id"))
         return
     }
+    fields := parseFields(c, speciesFieldPaths)
+    if invalidParams(c) { return }
     var raw bson.M
-    if err := sc.Collection.FindOne(db.Ctx, bson.M{"_id": oid}).Decode(&raw); err != nil {
+    if err := sc.Collection.FindOne(db.Ctx, bson.M{"_id": oid}, fields.FindOne()).Decode(&raw); err != nil {
         if errors.Is(err, mongo.ErrNoDocuments) {
             utils.NotFound(c)
             return
@@ -81,52 +98,83 @@ func (sc *SpeciesController) GetSpecies(c *gin.Context) {
         utils.ServerError(c, err)
         return
     }
-    c.JSON(http.StatusOK, mapSpecies(raw))
+    item, err := mapSpecies(raw)
+    if err != nil { utils.ServerError(c, err); return }
+    if notModified(c, etag(item.Version), item.UpdatedAt) { return }
+    c.JSON(http.StatusOK, fields.Apply(item))
 }
 
 func (sc *SpeciesController) ListSpecies(c *gin.Context) {
-    filter := bson.M{}
-    if name := strings.TrimSpace(c.Query("name")); name != "" {
-        filter["$or"] = []bson.M{
-            {"name": bson.M{"$regex": name, "$options": "i"}},
-            {"species_name": bson.M{"$regex": name, "$options": "i"}},
-        }
-    }
-    if category := strings.TrimSpace(c.Query("category")); category != "" {
-        ors := []bson.M{{"category": category}}
-        if oid, err := primitive.ObjectIDFromHex(category); err == nil {
-            ors = append(ors, bson.M{"category": oid})
-        }
-        filter["$or"] = appendOr(filter["$or"], ors...)
-    }
-    page := 1
-    limit := 10
-    if v, err := strconv.Atoi(c.DefaultQuery("page", "1")); err == nil && v > 0 {
-        page = v
-    }
-    if v, err := strconv.Atoi(c.DefaultQuery("limit", "10")); err == nil && v > 0 && v <= 100 {
-        limit = v
+    speciesList.List(c, sc.Collection)
+}
+
+// BatchGetSpecies returns the species with the ids of a {"ids": [...]} body
+func (sc *SpeciesController) BatchGetSpecies(c *gin.Context) {
+    speciesList.BatchGetJSON(c, sc.Collection)
+}
+
+func (sc *SpeciesController) ExportSpecies(c *gin.Context) {
+    speciesList.Export(c, sc.Collection, speciesExporter)
+}
+
+// speciesList declares how species are listed. category accepts ids or
+// category names.
+var speciesList = &listSpec{
+    Fields: map[string]listField{
+        "name":      {Type: query.String, Paths: []string{"name", "species_name"}},
+        "category":  {Type: query.Ref, Paths: []string{"category"}, Ref: &refTarget{"categories", []string{"name", "category_name"}}},
+        "createdAt": {Type: query.Time, Paths: []string{"createdAt"}},
+        "updatedAt": {Type: query.Time, Paths: []string{"updatedAt"}},
+    },
+    Params: []listParam{
+        {Name: "name", Kind: paramContains, Field: "name"},
+        {Name: "category", Kind: paramRef, Field: "category"},
+        {Name: "hasCategory", Kind: paramExists, Field: "category"},
+    },
+    Sorts: map[string]sortKey{
+        "name":         {Path: "name", Text: true},
+        "species_name": {Path: "species_name", Text: true},
+        "createdAt":    {Path: "createdAt"},
+        "updatedAt":    {Path: "updatedAt"},
+        // category name, stored as categorySort
+        "category": refSortKey(db.CategorySort),
+    },
+    DefaultSort: "createdAt",
+    Paths:       speciesFieldPaths,
+    Item: func(raw bson.Raw) (interface{}, error) {
+        return decodeSpecies(raw)
+    },
+}
+
+// UpdateSpecies replaces a species; createdAt is kept and an omitted category is cleared.
+func (sc *SpeciesController) UpdateSpecies(c *gin.Context) {
+    if sc.LegacyPut || legacyPutRequested(c) { sc.mergeSpecies(c); return }
+    oid, raw, ok := loadForWrite(c, sc.Collection)
+    if !ok { return }
+    type inBody struct {
+        Name        string `json:"name"`
+        SpeciesName string `json:"species_name"`
+        Category    string `json:"category"`
     }
-    skip := int64((page - 1) * limit)
-    sortField := c.DefaultQuery("sort", "createdAt")
-    allowed := map[string]bool{"name": true, "createdAt": true, "species_name": true}
-    if !allowed[sortField] { sortField = "createdAt" }
-    order := c.DefaultQuery("order", "desc")
-    sortDir := int32(-1); if strings.ToLower(order)=="asc" { sortDir=1 }
-    opts := options.Find().SetSkip(skip).SetLimit(int64(limit)).SetSort(bson.D{{Key: sortField, Value: sortDir}})
-    cur, err := sc.Collection.Find(db.Ctx, filter, opts)
-    if err != nil { utils.ServerError(c, err); return }
-    defer cur.Close(db.Ctx)
-    var raws []bson.M
-    if err := cur.All(db.Ctx, &raws); err != nil { utils.ServerError(c, err); return }
-    items := make([]models.Species, 0, len(raws))
-    for _, r := range raws { items = append(items, mapSpecies(r)) }
-    total, err := sc.Collection.CountDocuments(db.Ctx, filter)
+    var body inBody
+    if err := c.ShouldBindJSON(&body); err != nil { utils.BadRequest(c, err); return }
+    next := models.Species{ID: oid, Name: strings.TrimSpace(body.Name), Category: categoryRef(body.Category)}
+    if next.Name == "" { next.Name = strings.TrimSpace(body.SpeciesName) }
+    if err := validate.Struct(next); err != nil { utils.BadRequest(c, err); return }
+    prev, err := mapSpecies(raw)
     if err != nil { utils.ServerError(c, err); return }
-    c.JSON(http.StatusOK, gin.H{"items": items, "page": page, "limit": limit, "total": total})
+    next.CreatedAt = prev.CreatedAt
+    next.UpdatedAt = time.Now().UTC()
+    next.Version = docVersion(raw) + 1
+    if !saveReplace(c, sc.Collection, sc.Audit, "species", oid, raw, next) { return }
+    refreshSortKeys(sc.Collection, db.CategorySort, oid)
+    if next.Name != prev.Name { refreshReferrers(sc.Collection, db.SpeciesSort, oid) }
+    setETag(c, next.Version)
+    c.JSON(http.StatusOK, next)
 }
 
-func (sc *SpeciesController) UpdateSpecies(c *gin.Context) {
+// mergeSpecies is the pre-replace PUT behaviour: only provided fields are updated.
+func (sc *SpeciesController) mergeSpecies(c *gin.Context) {
     oid, err := primitive.ObjectIDFromHex(c.Param("id"))
     if err != nil { utils.BadRequest(c, errors.New("invalid id")); return }
     type inBody struct {
@@ -138,35 +186,85 @@ func (sc *SpeciesController) UpdateSpecies(c *gin.Context) {
     if err := c.ShouldBindJSON(&body); err != nil { utils.BadRequest(c, err); return }
     set := bson.M{"updatedAt": time.Now().UTC()}
     if n := strings.TrimSpace(body.Name); n != "" { set["name"] = n } else if n := strings.TrimSpace(body.SpeciesName); n != "" { set["name"] = n }
-    if body.Category != "" {
-        if oid, err := primitive.ObjectIDFromHex(body.Category); err == nil { set["category"] = oid } else { set["category"] = body.Category }
-    }
-    res := sc.Collection.FindOneAndUpdate(db.Ctx, bson.M{"_id": oid}, bson.M{"$set": set}, options.FindOneAndUpdate().SetReturnDocument(options.After))
-    var out models.Species
-    if err := res.Decode(&out); err != nil {
-        if errors.Is(err, mongo.ErrNoDocuments) { utils.NotFound(c); return }
+    if cat := categoryRef(body.Category); cat != "" { set["category"] = cat }
+    filter, ok := versionedFilter(c, oid)
+    if !ok { return }
+    update := bson.M{"$set": set, "$inc": bson.M{"version": int64(1)}}
+    res := sc.Collection.FindOneAndUpdate(db.Ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.Before))
+    var before bson.M
+    if err := res.Decode(&before); err != nil {
+        if errors.Is(err, mongo.ErrNoDocuments) { writeMiss(c, sc.Collection, oid); return }
         utils.ServerError(c, err); return
     }
+    after, err := applySet(before, set)
+    if err != nil { utils.ServerError(c, err); return }
+    sc.Audit.Record(c, "species", auditUpdate, oid, before, after)
+    if _, ok := set["category"]; ok { refreshSortKeys(sc.Collection, db.CategorySort, oid) }
+    if _, ok := set["name"]; ok { refreshReferrers(sc.Collection, db.SpeciesSort, oid) }
+    out, err := mapSpecies(after)
+    if err != nil { utils.ServerError(c, err); return }
+    setETag(c, out.Version)
+    c.JSON(http.StatusOK, out)
+}
+
+func (sc *SpeciesController) PatchSpecies(c *gin.Context) {
+    oid, raw, ok := loadForWrite(c, sc.Collection)
+    if !ok { return }
+    current, err := mapSpecies(raw)
+    if err != nil { utils.ServerError(c, err); return }
+    var next models.Species
+    if err := decodePatch(c, current, &next); err != nil { utils.BadRequest(c, err); return }
+    next.Name = strings.TrimSpace(next.Name)
+    if err := validate.Struct(next); err != nil { utils.BadRequest(c, err); return }
+    set, unset, err := patchUpdate(current, next)
+    if err != nil { utils.ServerError(c, err); return }
+    if cat, ok := set["category"].(string); ok { set["category"] = categoryRef(cat) }
+    after, ok := savePatch(c, sc.Collection, sc.Audit, "species", oid, raw, set, unset)
+    if !ok { return }
+    _, recategorized := set["category"]
+    if _, ok := unset["category"]; ok || recategorized { refreshSortKeys(sc.Collection, db.CategorySort, oid) }
+    if _, ok := set["name"]; ok { refreshReferrers(sc.Collection, db.SpeciesSort, oid) }
+    out, err := mapSpecies(after)
+    if err != nil { utils.ServerError(c, err); return }
+    setETag(c, out.Version)
     c.JSON(http.StatusOK, out)
 }
 
 func (sc *SpeciesController) DeleteSpecies(c *gin.Context) {
     oid, err := primitive.ObjectIDFromHex(c.Param("id"))
     if err != nil { utils.BadRequest(c, errors.New("invalid id")); return }
-    res, err := sc.Collection.DeleteOne(db.Ctx, bson.M{"_id": oid})
-    if err != nil { utils.ServerError(c, err); return }
-    if res.DeletedCount == 0 { utils.NotFound(c); return }
+    filter, ok := versionedFilter(c, oid)
+    if !ok { return }
+    var before bson.M
+    if err := sc.Collection.FindOneAndDelete(db.Ctx, filter).Decode(&before); err != nil {
+        if errors.Is(err, mongo.ErrNoDocuments) { writeMiss(c, sc.Collection, oid); return }
+        utils.ServerError(c, err); return
+    }
+    sc.Audit.Record(c, "species", auditDelete, oid, before, nil)
+    refreshReferrers(sc.Collection, db.SpeciesSort, oid)
     c.Status(http.StatusNoContent)
 }
 
-func mapSpecies(raw bson.M) models.Species {
+// SuggestSpecies autocompletes species names, tolerating typos.
+func (sc *SpeciesController) SuggestSpecies(c *gin.Context) {
+    suggest(c, sc.Collection, suggester{
+        fields: []string{"name", "species_name"},
+        item: func(raw bson.M) (suggestion, error) {
+            s, err := mapSpecies(raw)
+            return suggestion{ID: s.ID.Hex(), Name: s.Name, Category: s.Category}, err
+        },
+    })
+}
+
+func mapSpecies(raw bson.M) (models.Species, error) {
+    b, err := bson.Marshal(raw)
+    if err != nil { return models.Species{}, err }
+    return decodeSpecies(b)
+}
+
+// decodeSpecies reads a stored species without going through bson.M
+func decodeSpecies(raw bson.Raw) (models.Species, error) {
     var out models.Species
-    if id, ok := raw["_id"].(primitive.ObjectID); ok { out.ID = id }
-    if n, ok := raw["name"].(string); ok && n != "" { out.Name = n } else if sn, ok := raw["species_name"].(string); ok { out.Name = sn }
-    if c, ok := raw["category"].(string); ok { out.Category = c } else if coid, ok := raw["category"].(primitive.ObjectID); ok { out.Category = coid.Hex() }
-    if ct, ok := raw["createdAt"].(time.Time); ok { out.CreatedAt = ct }
-    if ut, ok := raw["updatedAt"].(time.Time); ok { out.UpdatedAt = ut }
-    if out.CreatedAt.IsZero() && out.ID != primitive.NilObjectID { out.CreatedAt = out.ID.Timestamp() }
-    if out.UpdatedAt.IsZero() { out.UpdatedAt = out.CreatedAt }
-    return out
+    err := out.UnmarshalBSON(raw)
+    return out, err
 }
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/bsontype"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestCategoryRef(t *testing.T) {
+    id := primitive.NewObjectID()
+    tests := map[string]string{
+        "":                              "",
+        id.Hex():                        id.Hex(),
+        " " + strings.ToUpper(id.Hex()): id.Hex(),
+        "Mammals":                       "Mammals",
+        " Birds ":                       "Birds",
+    }
+    for in, want := range tests {
+        if got := categoryRef(in); got != want {
+            t.Errorf("categoryRef(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
+
+// Replace, legacy merge and patch all store the category as its hex string.
+func TestSpeciesCategoryIsStoredAsHex(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    id, category := primitive.NewObjectID(), primitive.NewObjectID()
+    stored := bson.D{{Key: "_id", Value: id}, {Key: "name", Value: "Cat"}, {Key: "version", Value: int64(1)}}
+    body := `{"name":"Cat","category":"` + strings.ToUpper(category.Hex()) + `"}`
+
+    tests := []struct {
+        name    string
+        method  string
+        header  string
+        reads   bool
+        handler func(sc *SpeciesController) gin.HandlerFunc
+        // path of the category in the findAndModify update
+        path []string
+    }{
+        {"replace", "PUT", "", true, func(sc *SpeciesController) gin.HandlerFunc { return sc.UpdateSpecies }, []string{"category"}},
+        {"merge", "PUT", "true", false, func(sc *SpeciesController) gin.HandlerFunc { return sc.UpdateSpecies }, []string{"$set", "category"}},
+        {"patch", "PATCH", "", true, func(sc *SpeciesController) gin.HandlerFunc { return sc.PatchSpecies }, []string{"$set", "category"}},
+    }
+    for _, tt := range tests {
+        mt.Run(tt.name, func(mt *mtest.T) {
+            if tt.reads {
+                mt.AddMockResponses(cursorOf(mt, stored))
+            }
+            mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: stored}))
+            sc := &SpeciesController{Collection: mt.Coll}
+            w := httptest.NewRecorder()
+            c, _ := gin.CreateTestContext(w)
+            c.Params = gin.Params{{Key: "id", Value: id.Hex()}}
+            c.Request = httptest.NewRequest(tt.method, "/species/"+id.Hex(), strings.NewReader(body))
+            c.Request.Header.Set("Content-Type", "application/json")
+            if tt.header != "" {
+                c.Request.Header.Set("X-Legacy-Put", tt.header)
+            }
+            tt.handler(sc)(c)
+            if w.Code != http.StatusOK {
+                t.Fatalf("status %d: %s", w.Code, w.Body)
+            }
+            cmds := writeCommands(mt, "findAndModify")
+            if len(cmds) != 1 {
+                t.Fatalf("%d findAndModify commands, want 1", len(cmds))
+            }
+            v, err := cmds[0].Lookup("update").Document().LookupErr(tt.path...)
+            if err != nil {
+                t.Fatalf("no category in %v", cmds[0].Lookup("update"))
+            }
+            if v.Type != bsontype.String || v.StringValue() != category.Hex() {
+                t.Errorf("category stored as %v, want the string %q", v, category.Hex())
+            }
+        })
+    }
+}
@@ -0,0 +1,393 @@
+package controllers
+
+import (
+    "fmt"
+    "math"
+    "net/http"
+    "sort"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+
+    "go-api/pkg/utils"
+)
+
+type StatsController struct {
+    DB *mongo.Database
+}
+
+func NewStatsController(client *mongo.Client, dbName string) *StatsController {
+    return &StatsController{DB: client.Database(dbName)}
+}
+
+// ageHistogramBounds are the lower bounds of the age buckets; the last bucket is open.
+var ageHistogramBounds = []int{0, 1, 3, 6, 10, 15}
+
+// animalAgeExpr is the age of an animal as mapAnimal computes it: the stored
+// age, or else whole years since a (string or date) birthdate.
+var animalAgeExpr = bson.M{"$ifNull": bson.A{"$age", bson.M{"$dateDiff": bson.M{
+    "startDate": bson.M{"$convert": bson.M{"input": "$birthdate", "to": "date", "onError": nil, "onNull": nil}},
+    "endDate":   "$$NOW",
+    "unit":      "year",
+}}}}
+
+// refMatch matches documents of the looked-up collection referenced by the
+// variable ref, which holds an ObjectID, its hex string or (legacy) a name.
+func refMatch(ref string, nameFields ...string) bson.M {
+    ors := bson.A{
+        bson.M{"$eq": bson.A{"$_id", ref}},
+        bson.M{"$eq": bson.A{bson.M{"$toString": "$_id"}, ref}},
+    }
+    for _, f := range nameFields {
+        ors = append(ors, bson.M{"$and": bson.A{
+            bson.M{"$eq": bson.A{bson.M{"$type": ref}, "string"}},
+            bson.M{"$eq": bson.A{bson.M{"$toLower": "$" + f}, bson.M{"$toLower": ref}}},
+        }})
+    }
+    return bson.M{"$match": bson.M{"$expr": bson.M{"$or": ors}}}
+}
+
+type speciesStat struct {
+    Species    string   `json:"species"`
+    Name       string   `json:"name,omitempty"`
+    Category   string   `json:"category,omitempty"`
+    Count      int      `json:"count"`
+    Adopted    int      `json:"adopted"`
+    Available  int      `json:"available"`
+    AverageAge *float64 `json:"averageAge"`
+}
+
+type categoryStat struct {
+    Category string `json:"category"`
+    Name     string `json:"name,omitempty"`
+    Count    int    `json:"count"`
+}
+
+type ageBucket struct {
+    Label string `json:"label"`
+    Min   *int   `json:"min"`
+    Max   *int   `json:"max"` // inclusive; null for the open last bucket
+    Count int    `json:"count"`
+}
+
+// AnimalStats godoc
+// @Summary Animal statistics
+// @Description Counts by species (with average age) and by category (joined through species), adopted vs
+// @Description available, and an age histogram. Accepts the same filters as GET /animals.
+// @Tags stats
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /stats/animals [get]
+func (sc *StatsController) AnimalStats(c *gin.Context) {
+    filter, err := animalList.Filter(c, sc.DB)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    if invalidParams(c) {
+        return
+    }
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: filter}},
+        {{Key: "$set", Value: bson.M{"_age": animalAgeExpr}}},
+        {{Key: "$facet", Value: bson.M{
+            "total": bson.A{bson.M{"$count": "n"}},
+            "adopted": bson.A{
+                bson.M{"$group": bson.M{"_id": bson.M{"$eq": bson.A{"$adopted", true}}, "n": bson.M{"$sum": 1}}},
+            },
+            "ages": bson.A{ageBucketStage("$_age")},
+            "species": bson.A{
+                bson.M{"$group": bson.M{
+                    "_id":     "$species",
+                    "n":       bson.M{"$sum": 1},
+                    "adopted": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$adopted", true}}, 1, 0}}},
+                    "avgAge":  bson.M{"$avg": "$_age"},
+                }},
+                bson.M{"$lookup": bson.M{
+                    "from":     "species",
+                    "let":      bson.M{"ref": "$_id"},
+                    "pipeline": bson.A{refMatch("$$ref", "name", "species_name"), bson.M{"$limit": 1}},
+                    "as":       "sp",
+                }},
+                bson.M{"$set": bson.M{"sp": bson.M{"$arrayElemAt": bson.A{"$sp", 0}}}},
+                bson.M{"$lookup": bson.M{
+                    "from":     "categories",
+                    "let":      bson.M{"ref": "$sp.category"},
+                    "pipeline": bson.A{refMatch("$$ref", "name", "category_name"), bson.M{"$limit": 1}},
+                    "as":       "cat",
+                }},
+                bson.M{"$set": bson.M{"cat": bson.M{"$arrayElemAt": bson.A{"$cat", 0}}}},
+                bson.M{"$project": bson.M{
+                    "n": 1, "adopted": 1, "avgAge": 1,
+                    "speciesId":    "$sp._id",
+                    "speciesName":  bson.M{"$ifNull": bson.A{"$sp.name", "$sp.species_name"}},
+                    "categoryRef":  "$sp.category",
+                    "categoryId":   "$cat._id",
+                    "categoryName": bson.M{"$ifNull": bson.A{"$cat.name", "$cat.category_name"}},
+                }},
+                bson.M{"$sort": bson.D{{Key: "n", Value: -1}}},
+            },
+        }}},
+    }
+    cur, err := sc.DB.Collection("animals").Aggregate(c.Request.Context(), pipeline)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    var out []struct {
+        Total   []struct{ N int `bson:"n"` } `bson:"total"`
+        Adopted []struct {
+            ID bool `bson:"_id"`
+            N  int  `bson:"n"`
+        } `bson:"adopted"`
+        Ages    []countRow `bson:"ages"`
+        Species []struct {
+            ID           interface{} `bson:"_id"`
+            N            int         `bson:"n"`
+            Adopted      int         `bson:"adopted"`
+            AvgAge       *float64    `bson:"avgAge"`
+            SpeciesID    interface{} `bson:"speciesId"`
+            SpeciesName  string      `bson:"speciesName"`
+            CategoryRef  interface{} `bson:"categoryRef"`
+            CategoryID   interface{} `bson:"categoryId"`
+            CategoryName string      `bson:"categoryName"`
+        } `bson:"species"`
+    }
+    if err := cur.All(c.Request.Context(), &out); err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    res := out[0]
+
+    total := 0
+    if len(res.Total) > 0 {
+        total = res.Total[0].N
+    }
+    adopted := 0
+    for _, a := range res.Adopted {
+        if a.ID {
+            adopted = a.N
+        }
+    }
+
+    ages := ageHistogram(res.Ages)
+
+    bySpecies := make([]speciesStat, 0, len(res.Species))
+    categories := map[string]*categoryStat{}
+    for _, s := range res.Species {
+        st := speciesStat{
+            Species:   refString(s.ID),
+            Name:      s.SpeciesName,
+            Count:     s.N,
+            Adopted:   s.Adopted,
+            Available: s.N - s.Adopted,
+        }
+        if s.SpeciesID != nil {
+            st.Species = refString(s.SpeciesID)
+        }
+        if s.AvgAge != nil {
+            avg := math.Round(*s.AvgAge*10) / 10
+            st.AverageAge = &avg
+        }
+        catKey := refString(s.CategoryRef)
+        if s.CategoryID != nil {
+            catKey = refString(s.CategoryID)
+        }
+        st.Category = catKey
+        bySpecies = append(bySpecies, st)
+
+        cs, ok := categories[catKey]
+        if !ok {
+            cs = &categoryStat{Category: catKey, Name: s.CategoryName}
+            categories[catKey] = cs
+        }
+        cs.Count += s.N
+    }
+    byCategory := make([]categoryStat, 0, len(categories))
+    for _, cs := range categories {
+        byCategory = append(byCategory, *cs)
+    }
+    sort.Slice(byCategory, func(i, j int) bool {
+        if byCategory[i].Count != byCategory[j].Count {
+            return byCategory[i].Count > byCategory[j].Count
+        }
+        return byCategory[i].Category < byCategory[j].Category
+    })
+
+    c.JSON(http.StatusOK, gin.H{
+        "total":        total,
+        "adopted":      adopted,
+        "available":    total - adopted,
+        "bySpecies":    bySpecies,
+        "byCategory":   byCategory,
+        "ageHistogram": ages,
+    })
+}
+
+// SpeciesStats godoc
+// @Summary Animal counts per species
+// @Description Every species with its number of animals (adopted and available), including species
+// @Description without animals. Animals referencing no known species are listed with their raw reference.
+// @Tags stats
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /stats/species [get]
+func (sc *StatsController) SpeciesStats(c *gin.Context) {
+    ctx := c.Request.Context()
+    pipeline := mongo.Pipeline{
+        {{Key: "$lookup", Value: bson.M{
+            "from": "animals",
+            "let":  bson.M{"id": "$_id", "hex": bson.M{"$toString": "$_id"}, "name": bson.M{"$toLower": bson.M{"$ifNull": bson.A{"$name", "$species_name"}}}},
+            "pipeline": bson.A{
+                bson.M{"$match": bson.M{"$expr": bson.M{"$or": bson.A{
+                    bson.M{"$eq": bson.A{"$species", "$$id"}},
+                    bson.M{"$eq": bson.A{"$species", "$$hex"}},
+                    bson.M{"$and": bson.A{
+                        bson.M{"$eq": bson.A{bson.M{"$type": "$species"}, "string"}},
+                        bson.M{"$eq": bson.A{bson.M{"$toLower": "$species"}, "$$name"}},
+                    }},
+                }}}},
+                bson.M{"$group": bson.M{
+                    "_id":     nil,
+                    "n":       bson.M{"$sum": 1},
+                    "adopted": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$adopted", true}}, 1, 0}}},
+                }},
+            },
+            "as": "counts",
+        }}},
+        {{Key: "$set", Value: bson.M{"counts": bson.M{"$arrayElemAt": bson.A{"$counts", 0}}}}},
+        {{Key: "$project", Value: bson.M{
+            "name":     bson.M{"$ifNull": bson.A{"$name", "$species_name"}},
+            "category": 1,
+            "n":        bson.M{"$ifNull": bson.A{"$counts.n", 0}},
+            "adopted":  bson.M{"$ifNull": bson.A{"$counts.adopted", 0}},
+        }}},
+        {{Key: "$sort", Value: bson.D{{Key: "n", Value: -1}, {Key: "name", Value: 1}}}},
+    }
+    cur, err := sc.DB.Collection("species").Aggregate(ctx, pipeline)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    var rows []struct {
+        ID       primitive.ObjectID `bson:"_id"`
+        Name     string             `bson:"name"`
+        Category interface{}        `bson:"category"`
+        N        int                `bson:"n"`
+        Adopted  int                `bson:"adopted"`
+    }
+    if err := cur.All(ctx, &rows); err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    items := make([]speciesStat, 0, len(rows))
+    counted := 0
+    for _, r := range rows {
+        items = append(items, speciesStat{
+            Species:   r.ID.Hex(),
+            Name:      r.Name,
+            Category:  refString(r.Category),
+            Count:     r.N,
+            Adopted:   r.Adopted,
+            Available: r.N - r.Adopted,
+        })
+        counted += r.N
+    }
+    total, err := sc.DB.Collection("animals").CountDocuments(ctx, bson.M{})
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "items": items,
+        "total": total,
+        // Animals whose species reference matches no species document.
+        "unmatched": total - int64(counted),
+    })
+}
+
+// refString renders a reference (ObjectID or string) as a string.
+// countRow is one group of a $group or $bucket stage counting into n.
+type countRow struct {
+    ID interface{} `bson:"_id"`
+    N  int         `bson:"n"`
+}
+
+// ageBucketStage counts documents per ageHistogramBounds bucket of age,
+// which is read for ageHistogram.
+func ageBucketStage(age interface{}) bson.M {
+    boundaries := bson.A{}
+    for _, b := range ageHistogramBounds {
+        boundaries = append(boundaries, b)
+    }
+    boundaries = append(boundaries, math.MaxInt32)
+    return bson.M{"$bucket": bson.M{
+        "groupBy":    age,
+        "boundaries": boundaries,
+        "default":    "unknown",
+        "output":     bson.M{"n": bson.M{"$sum": 1}},
+    }}
+}
+
+// ageHistogram turns the rows of a $bucket over ageHistogramBounds into
+// labelled buckets, every bound included, plus unknown when non-zero.
+func ageHistogram(rows []countRow) []ageBucket {
+    ages := make([]ageBucket, 0, len(ageHistogramBounds)+1)
+    counts := map[int]int{}
+    unknown := 0
+    for _, b := range rows {
+        if lo, ok := toInt(b.ID); ok {
+            counts[lo] = b.N
+        } else {
+            unknown += b.N
+        }
+    }
+    for i, lo := range ageHistogramBounds {
+        lo := lo
+        bucket := ageBucket{Min: &lo, Count: counts[lo]}
+        if i+1 < len(ageHistogramBounds) {
+            hi := ageHistogramBounds[i+1] - 1
+            bucket.Max = &hi
+            if hi == lo {
+                bucket.Label = fmt.Sprint(lo)
+            } else {
+                bucket.Label = fmt.Sprintf("%d-%d", lo, hi)
+            }
+        } else {
+            bucket.Label = fmt.Sprintf("%d+", lo)
+        }
+        ages = append(ages, bucket)
+    }
+    if unknown > 0 {
+        ages = append(ages, ageBucket{Label: "unknown", Count: unknown})
+    }
+    return ages
+}
+
+func refString(v interface{}) string {
+    switch r := v.(type) {
+    case primitive.ObjectID:
+        return r.Hex()
+    case string:
+        return strings.TrimSpace(r)
+    case nil:
+        return ""
+    }
+    return fmt.Sprint(v)
+}
+
+func toInt(v interface{}) (int, bool) {
+    switch n := v.(type) {
+    case int32:
+        return int(n), true
+    case int64:
+        return int(n), true
+    case float64:
+        return int(n), true
+    }
+    return 0, false
+}
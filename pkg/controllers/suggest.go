@@ -0,0 +1,271 @@
+package controllers
+
+import (
+    "errors"
+    "log"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+    "go-api/pkg/utils"
+)
+
+// maxFuzzyCandidates caps how many documents are read to rank fuzzy matches.
+const maxFuzzyCandidates = 2000
+
+// suggestIndexes holds a *sync.Once per collection for ensureSuggestIndexes.
+var suggestIndexes sync.Map
+
+// suggester describes a collection offering name autocomplete.
+type suggester struct {
+    // fields holding the name; the first one is preferred.
+    fields []string
+    // item fills ID, Name and the resource-specific fields of a suggestion.
+    // Documents it fails on are not suggested.
+    item func(raw bson.M) (suggestion, error)
+}
+
+// suggestion is one autocomplete result. Match is "exact", "prefix" or "fuzzy".
+type suggestion struct {
+    ID       string  `json:"id"`
+    Name     string  `json:"name"`
+    Match    string  `json:"match"`
+    Distance int     `json:"distance"`
+    Score    float64 `json:"score"`
+    Species  string  `json:"species,omitempty"`
+    Category string  `json:"category,omitempty"`
+}
+
+// suggest answers an autocomplete request: names starting with q first, then,
+// if there is room and fuzzy is not disabled, names within a small edit
+// distance or with similar trigrams (e.g. "Gustav" for "Gustave").
+func suggest(c *gin.Context, coll *mongo.Collection, s suggester) {
+    q := strings.TrimSpace(c.Query("q"))
+    if q == "" {
+        utils.BadRequest(c, errors.New("q is required"))
+        return
+    }
+    limit := 10
+    if v := c.Query("limit"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < 1 || n > 50 {
+            utils.BadRequest(c, errors.New("limit must be between 1 and 50"))
+            return
+        }
+        limit = n
+    }
+    fuzzy := true
+    if v := c.Query("fuzzy"); v != "" {
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            utils.BadRequest(c, errors.New("fuzzy must be true or false"))
+            return
+        }
+        fuzzy = b
+    }
+    ctx := c.Request.Context()
+    lq := strings.ToLower(q)
+    ensureSuggestIndexes(coll, s.fields)
+
+    // Prefix matches, as collated ranges served by the name indexes.
+    opts := options.Find().SetCollation(listCollation).SetLimit(int64(limit)).SetSort(bson.D{{Key: s.fields[0], Value: 1}})
+    cur, err := coll.Find(ctx, prefixFilter(s.fields, q), opts)
+    if err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    var raws []bson.M
+    if err := cur.All(ctx, &raws); err != nil {
+        utils.ServerError(c, err)
+        return
+    }
+    items := []suggestion{}
+    seen := map[string]bool{}
+    for _, raw := range raws {
+        sg, err := s.item(raw)
+        if err != nil {
+            skipUndecodable(coll.Name(), raw["_id"], err)
+            continue
+        }
+        sg.Match, sg.Score = "prefix", 1
+        if strings.ToLower(sg.Name) == lq {
+            sg.Match = "exact"
+        } else {
+            sg.Distance = utils.EditDistance(lq, strings.ToLower(sg.Name))
+        }
+        seen[sg.ID] = true
+        items = append(items, sg)
+    }
+
+    if fuzzy && len(items) < limit {
+        more, err := fuzzySuggestions(c, coll, s, lq, limit-len(items), seen)
+        if err != nil {
+            utils.ServerError(c, err)
+            return
+        }
+        items = append(items, more...)
+    }
+    // Exact matches first, then prefix matches, then fuzzy ones by score.
+    rank := map[string]int{"exact": 0, "prefix": 1, "fuzzy": 2}
+    sort.SliceStable(items, func(i, j int) bool {
+        if rank[items[i].Match] != rank[items[j].Match] {
+            return rank[items[i].Match] < rank[items[j].Match]
+        }
+        return items[i].Score > items[j].Score
+    })
+    c.JSON(http.StatusOK, gin.H{"query": q, "items": items})
+}
+
+// fuzzySuggestions ranks names against q and returns documents carrying the
+// closest ones, skipping ids already suggested. Typos rarely hit the first
+// letter, so only names sharing it with q are candidates: an index range
+// capped at maxFuzzyCandidates documents rather than the whole collection.
+func fuzzySuggestions(c *gin.Context, coll *mongo.Collection, s suggester, lq string, limit int, seen map[string]bool) ([]suggestion, error) {
+    ctx := c.Request.Context()
+    type candidate struct {
+        name     string
+        distance int
+        score    float64
+    }
+    proj := bson.M{}
+    for _, f := range s.fields {
+        proj[f] = 1
+    }
+    opts := options.Find().SetCollation(listCollation).SetLimit(maxFuzzyCandidates).SetProjection(proj)
+    cur, err := coll.Find(ctx, prefixFilter(s.fields, string([]rune(lq)[:1])), opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cur.Close(ctx)
+    var candidates []candidate
+    checked := map[string]bool{}
+    for cur.Next(ctx) {
+        for _, f := range s.fields {
+            name, ok := cur.Current.Lookup(f).StringValueOK()
+            if !ok || checked[name] {
+                continue
+            }
+            checked[name] = true
+            if d, score, ok := fuzzyMatch(lq, strings.ToLower(name)); ok {
+                candidates = append(candidates, candidate{name: name, distance: d, score: score})
+            }
+        }
+    }
+    if err := cur.Err(); err != nil {
+        return nil, err
+    }
+    sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+    if len(candidates) > limit {
+        candidates = candidates[:limit]
+    }
+    if len(candidates) == 0 {
+        return nil, nil
+    }
+    byName := map[string]candidate{}
+    names := make([]string, 0, len(candidates))
+    for _, cd := range candidates {
+        byName[cd.name] = cd
+        names = append(names, cd.name)
+    }
+    or := make([]bson.M, 0, len(s.fields))
+    for _, f := range s.fields {
+        or = append(or, bson.M{f: bson.M{"$in": names}})
+    }
+    cur, err = coll.Find(ctx, bson.M{"$or": or}, options.Find().SetLimit(int64(limit+len(seen))))
+    if err != nil {
+        return nil, err
+    }
+    var raws []bson.M
+    if err := cur.All(ctx, &raws); err != nil {
+        return nil, err
+    }
+    var out []suggestion
+    for _, raw := range raws {
+        sg, err := s.item(raw)
+        if err != nil {
+            skipUndecodable(coll.Name(), raw["_id"], err)
+            continue
+        }
+        cd, ok := byName[sg.Name]
+        if !ok || seen[sg.ID] {
+            continue
+        }
+        seen[sg.ID] = true
+        sg.Match, sg.Distance, sg.Score = "fuzzy", cd.distance, cd.score
+        out = append(out, sg)
+        if len(out) == limit {
+            break
+        }
+    }
+    return out, nil
+}
+
+// prefixFilter matches documents with any of fields starting with prefix
+// under listCollation, i.e. ignoring case. U+FFFF sorts after every
+// character in ICU collations, so it closes the range; unlike a
+// case-insensitive regex, the range can use an index with that collation.
+func prefixFilter(fields []string, prefix string) bson.M {
+    or := make([]bson.M, 0, len(fields))
+    for _, f := range fields {
+        or = append(or, bson.M{f: bson.M{"$gte": prefix, "$lt": prefix + "\uffff"}})
+    }
+    return bson.M{"$or": or}
+}
+
+// ensureSuggestIndexes creates, once per process and collection, an index on
+// each name field with listCollation for the prefix ranges of suggest.
+func ensureSuggestIndexes(coll *mongo.Collection, fields []string) {
+    once, _ := suggestIndexes.LoadOrStore(coll.Name(), &sync.Once{})
+    once.(*sync.Once).Do(func() {
+        for _, f := range fields {
+            _, err := coll.Indexes().CreateOne(db.Ctx, mongo.IndexModel{
+                Keys:    bson.D{{Key: f, Value: 1}},
+                Options: options.Index().SetName("suggest_" + f).SetCollation(listCollation),
+            })
+            if err != nil {
+                log.Printf("suggest: index on %s.%s: %v", coll.Name(), f, err)
+            }
+        }
+    })
+}
+
+// fuzzyMatch compares query q with name (both lower case). While typing, q
+// is often a misspelled prefix, so the distance to the start of name counts
+// too. Allowed distance grows with the length of q.
+func fuzzyMatch(q, name string) (distance int, score float64, ok bool) {
+    distance = utils.EditDistance(q, name)
+    if rq, rn := []rune(q), []rune(name); len(rn) > len(rq) {
+        if d := utils.EditDistance(q, string(rn[:len(rq)])); d < distance {
+            distance = d
+        }
+    }
+    n := len([]rune(q))
+    allowed := 1
+    switch {
+    case n <= 2:
+        allowed = 0
+    case n > 8:
+        allowed = 3
+    case n > 5:
+        allowed = 2
+    }
+    trigram := utils.TrigramSimilarity(q, name)
+    if distance > allowed && trigram < 0.5 {
+        return 0, 0, false
+    }
+    longest := max(n, len([]rune(name)))
+    score = 1 - float64(distance)/float64(longest)
+    if trigram > score {
+        score = trigram
+    }
+    // Keep fuzzy scores below the 1 given to prefix matches.
+    return distance, score * 0.99, true
+}
@@ -0,0 +1,655 @@
+// Package dataset loads raw dumps of the original animals dataset (mongoexport
+// JSON or mongodump BSON) into the canonical schema.
+//
+// Source documents use the dataset's own field names (animal_name,
+// species_name, category_name, birthdate) and reference each other by
+// ObjectID. Every source id is remapped to an id in our database; the mapping
+// is kept in the dataset_ids collection so running the import again updates
+// the same documents instead of creating duplicates.
+package dataset
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "time"
+
+    "github.com/go-playground/validator/v10"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "go-api/pkg/db"
+    "go-api/pkg/models"
+    "go-api/pkg/utils"
+)
+
+const mappingCollection = "dataset_ids"
+
+var validate = validator.New()
+
+// Files holds the dumps to import. Any of them may be nil.
+type Files struct {
+    Categories []bson.M
+    Species    []bson.M
+    Animals    []bson.M
+}
+
+// Result summarizes the import of one collection.
+type Result struct {
+    Read      int      `json:"read"`
+    Created   int      `json:"created"`
+    Updated   int      `json:"updated"`
+    Unchanged int      `json:"unchanged"`
+    Skipped   int      `json:"skipped"`
+    Errors    []string `json:"errors,omitempty"`
+}
+
+func (r *Result) skip(i int, format string, args ...interface{}) {
+    r.Skipped++
+    r.Errors = append(r.Errors, fmt.Sprintf("document %d: ", i)+fmt.Sprintf(format, args...))
+}
+
+// Report summarizes a whole import.
+type Report struct {
+    Categories Result `json:"categories"`
+    Species    Result `json:"species"`
+    Animals    Result `json:"animals"`
+}
+
+// AuditFunc records a created or updated document. before is nil for creates.
+type AuditFunc func(collection, action string, id primitive.ObjectID, before, after bson.M)
+
+type Importer struct {
+    DB *mongo.Database
+    // Audit, when set, is called for every document the import writes.
+    Audit AuditFunc
+}
+
+func NewImporter(database *mongo.Database) *Importer {
+    return &Importer{DB: database}
+}
+
+// Run imports categories, then species, then animals, so references can be
+// remapped to ids created earlier in the same run or in previous runs.
+func (im *Importer) Run(ctx context.Context, files Files) (Report, error) {
+    var rep Report
+    _, err := im.DB.Collection(mappingCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys:    bson.D{{Key: "collection", Value: 1}, {Key: "sourceId", Value: 1}},
+        Options: options.Index().SetUnique(true),
+    })
+    if err != nil {
+        return rep, err
+    }
+
+    categoryIDs, err := im.load(ctx, "categories", files.Categories, &rep.Categories, func(i int, raw bson.M) (interface{}, bool) {
+        m := models.Category{Name: firstString(raw, "name", "category_name")}
+        if err := validate.Struct(m); err != nil {
+            rep.Categories.skip(i, "%v", err)
+            return nil, false
+        }
+        return &m, true
+    })
+    if err != nil {
+        return rep, err
+    }
+
+    speciesRefs := refs(files.Species, "category")
+    categoryIDs, err = im.resolve(ctx, "categories", categoryIDs, speciesRefs)
+    if err != nil {
+        return rep, err
+    }
+    speciesIDs, err := im.load(ctx, "species", files.Species, &rep.Species, func(i int, raw bson.M) (interface{}, bool) {
+        m := models.Species{Name: firstString(raw, "name", "species_name")}
+        if ref, ok := refKey(raw["category"]); ok {
+            id, found := categoryIDs[ref]
+            if !found {
+                rep.Species.skip(i, "unknown category %s", ref)
+                return nil, false
+            }
+            m.Category = id.Hex()
+        } else if s, ok := raw["category"].(string); ok {
+            m.Category = s
+        }
+        if err := validate.Struct(m); err != nil {
+            rep.Species.skip(i, "%v", err)
+            return nil, false
+        }
+        return &m, true
+    })
+    if err != nil {
+        return rep, err
+    }
+
+    animalRefs := refs(files.Animals, "species")
+    speciesIDs, err = im.resolve(ctx, "species", speciesIDs, animalRefs)
+    if err != nil {
+        return rep, err
+    }
+    _, err = im.load(ctx, "animals", files.Animals, &rep.Animals, func(i int, raw bson.M) (interface{}, bool) {
+        m, err := canonicalAnimal(raw)
+        if err != nil {
+            rep.Animals.skip(i, "%v", err)
+            return nil, false
+        }
+        if ref, ok := refKey(raw["species"]); ok {
+            id, found := speciesIDs[ref]
+            if !found {
+                rep.Animals.skip(i, "unknown species %s", ref)
+                return nil, false
+            }
+            m.Species = id.Hex()
+        }
+        if err := validate.Struct(m); err != nil {
+            rep.Animals.skip(i, "%v", err)
+            return nil, false
+        }
+        return &m, true
+    })
+    if err != nil {
+        return rep, err
+    }
+    // Species and category names may have changed along with the references.
+    return rep, db.BackfillSortKeys(ctx, im.DB)
+}
+
+// load upserts the canonical form of docs into collection and returns the
+// source id -> target id mapping of everything it wrote or found unchanged.
+// New documents get their target id reserved in the mapping before they are
+// inserted with it, so repeating an interrupted run creates no duplicates.
+// build returns a pointer to the canonical model, or false to skip the document.
+func (im *Importer) load(ctx context.Context, collection string, docs []bson.M, res *Result, build func(int, bson.M) (interface{}, bool)) (map[string]primitive.ObjectID, error) {
+    res.Read = len(docs)
+    ids := map[string]primitive.ObjectID{}
+    if len(docs) == 0 {
+        return ids, nil
+    }
+    keys := make([]string, len(docs))
+    for i, raw := range docs {
+        key, ok := refKey(raw["_id"])
+        if !ok {
+            res.skip(i, "missing or non-ObjectID _id")
+            continue
+        }
+        keys[i] = key
+    }
+    known, err := im.mapping(ctx, collection, keys)
+    if err != nil {
+        return nil, err
+    }
+    targets := make([]primitive.ObjectID, 0, len(known))
+    for _, id := range known {
+        targets = append(targets, id)
+    }
+    existing, err := im.fetch(ctx, collection, targets)
+    if err != nil {
+        return nil, err
+    }
+
+    // Build every document first, so only documents that will be written
+    // get an id reserved.
+    type built struct {
+        raw    bson.M
+        fields bson.M
+    }
+    docsByKey := map[string]built{}
+    order := make([]string, 0, len(docs))
+    var unmapped []string
+    for i, raw := range docs {
+        key := keys[i]
+        if key == "" {
+            continue
+        }
+        if _, dup := docsByKey[key]; dup {
+            res.skip(i, "duplicate _id %s", key)
+            continue
+        }
+        model, ok := build(i, raw)
+        if !ok {
+            continue
+        }
+        fields, err := canonicalFields(model)
+        if err != nil {
+            return nil, err
+        }
+        docsByKey[key] = built{raw, fields}
+        order = append(order, key)
+        if _, mapped := known[key]; !mapped {
+            unmapped = append(unmapped, key)
+        }
+    }
+
+    // The source id -> target id mapping is written before the documents, so
+    // a run interrupted in between inserts the same ids when repeated.
+    now := time.Now().UTC()
+    if len(unmapped) > 0 {
+        reserved, err := im.reserve(ctx, collection, unmapped, now)
+        if err != nil {
+            return nil, err
+        }
+        for k, v := range reserved {
+            known[k] = v
+        }
+    }
+
+    // audits[i] describes writes[i] for the audit log.
+    type audit struct {
+        action        string
+        id            primitive.ObjectID
+        before, after bson.M
+    }
+    var writes []mongo.WriteModel
+    var audits []audit
+    for _, key := range order {
+        b := docsByKey[key]
+        fields := b.fields
+        target := known[key]
+        current, exists := existing[target]
+        switch {
+        case exists && sameFields(current, fields):
+            res.Unchanged++
+        case exists:
+            fields["updatedAt"] = now
+            writes = append(writes, mongo.NewUpdateOneModel().
+                SetFilter(bson.M{"_id": target}).
+                SetUpdate(bson.M{"$set": fields, "$inc": bson.M{"version": int64(1)}}))
+            audits = append(audits, audit{"update", target, current, updated(current, fields)})
+            res.Updated++
+        default:
+            // New, imported before but deleted since, or reserved by an
+            // interrupted run: (re)create it.
+            fields["_id"] = target
+            fields["createdAt"] = sourceCreatedAt(b.raw, now)
+            fields["updatedAt"] = now
+            fields["version"] = int64(1)
+            writes = append(writes, mongo.NewInsertOneModel().SetDocument(fields))
+            audits = append(audits, audit{"create", target, nil, fields})
+            res.Created++
+        }
+        ids[key] = target
+    }
+    if len(writes) > 0 {
+        _, err := im.DB.Collection(collection).BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+        if err != nil && !onlyDuplicates(err) {
+            return nil, fmt.Errorf("writing %s: %w", collection, err)
+        }
+        if im.Audit != nil {
+            // Duplicate inserts were written by a concurrent import, which
+            // records them itself.
+            failed := map[int]bool{}
+            var bwe mongo.BulkWriteException
+            if errors.As(err, &bwe) {
+                for _, we := range bwe.WriteErrors {
+                    failed[we.Index] = true
+                }
+            }
+            for i, a := range audits {
+                if !failed[i] {
+                    im.Audit(collection, a.action, a.id, a.before, a.after)
+                }
+            }
+        }
+    }
+    return ids, nil
+}
+
+// resolve adds previously imported ids for references that are not part of
+// this run, e.g. species pointing at categories imported last week.
+func (im *Importer) resolve(ctx context.Context, collection string, ids map[string]primitive.ObjectID, refs []string) (map[string]primitive.ObjectID, error) {
+    var missing []string
+    for _, r := range refs {
+        if _, ok := ids[r]; !ok {
+            missing = append(missing, r)
+        }
+    }
+    found, err := im.mapping(ctx, collection, missing)
+    if err != nil {
+        return nil, err
+    }
+    for k, v := range found {
+        ids[k] = v
+    }
+    return ids, nil
+}
+
+// reserve maps each of keys to a new target id and returns the mapping as
+// stored. A concurrent import may have mapped a key first; its id wins.
+func (im *Importer) reserve(ctx context.Context, collection string, keys []string, now time.Time) (map[string]primitive.ObjectID, error) {
+    maps := make([]mongo.WriteModel, len(keys))
+    for i, key := range keys {
+        maps[i] = mongo.NewUpdateOneModel().
+            SetFilter(bson.M{"collection": collection, "sourceId": key}).
+            SetUpdate(bson.M{"$setOnInsert": bson.M{"targetId": primitive.NewObjectID(), "importedAt": now}}).
+            SetUpsert(true)
+    }
+    if _, err := im.DB.Collection(mappingCollection).BulkWrite(ctx, maps, options.BulkWrite().SetOrdered(false)); err != nil && !onlyDuplicates(err) {
+        return nil, fmt.Errorf("writing %s id mapping: %w", collection, err)
+    }
+    return im.mapping(ctx, collection, keys)
+}
+
+// onlyDuplicates reports whether err is a bulk write error made only of
+// duplicate keys, left by a concurrent import writing the same ids.
+func onlyDuplicates(err error) bool {
+    var bwe mongo.BulkWriteException
+    if !errors.As(err, &bwe) || bwe.WriteConcernError != nil || len(bwe.WriteErrors) == 0 {
+        return false
+    }
+    for _, we := range bwe.WriteErrors {
+        if !mongo.IsDuplicateKeyError(we) {
+            return false
+        }
+    }
+    return true
+}
+
+// mapping looks up target ids of previously imported source ids.
+func (im *Importer) mapping(ctx context.Context, collection string, keys []string) (map[string]primitive.ObjectID, error) {
+    out := map[string]primitive.ObjectID{}
+    if len(keys) == 0 {
+        return out, nil
+    }
+    cur, err := im.DB.Collection(mappingCollection).Find(ctx, bson.M{"collection": collection, "sourceId": bson.M{"$in": keys}})
+    if err != nil {
+        return nil, err
+    }
+    defer cur.Close(ctx)
+    for cur.Next(ctx) {
+        var m struct {
+            SourceID string             `bson:"sourceId"`
+            TargetID primitive.ObjectID `bson:"targetId"`
+        }
+        if err := cur.Decode(&m); err != nil {
+            return nil, err
+        }
+        out[m.SourceID] = m.TargetID
+    }
+    return out, cur.Err()
+}
+
+func (im *Importer) fetch(ctx context.Context, collection string, ids []primitive.ObjectID) (map[primitive.ObjectID]bson.M, error) {
+    out := map[primitive.ObjectID]bson.M{}
+    if len(ids) == 0 {
+        return out, nil
+    }
+    cur, err := im.DB.Collection(collection).Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+    if err != nil {
+        return nil, err
+    }
+    defer cur.Close(ctx)
+    for cur.Next(ctx) {
+        var raw bson.M
+        if err := cur.Decode(&raw); err != nil {
+            return nil, err
+        }
+        if id, ok := raw["_id"].(primitive.ObjectID); ok {
+            out[id] = raw
+        }
+    }
+    return out, cur.Err()
+}
+
+// canonicalAnimal converts a dataset animal, leaving the species reference to the caller.
+func canonicalAnimal(raw bson.M) (models.Animal, error) {
+    m := models.Animal{Name: firstString(raw, "name", "animal_name")}
+    if s, ok := raw["species"].(string); ok {
+        m.Species = s
+    }
+    switch v := raw["age"].(type) {
+    case int32:
+        m.Age = int(v)
+    case int64:
+        m.Age = int(v)
+    case float64:
+        m.Age = int(v)
+    default:
+        switch bd := raw["birthdate"].(type) {
+        case string:
+            if age := utils.AgeFromBirthdate(bd); age >= 0 {
+                m.Age = age
+            } else if bd != "" {
+                return m, fmt.Errorf("invalid birthdate %q", bd)
+            }
+        case primitive.DateTime:
+            if age := utils.AgeFromTime(bd.Time()); age >= 0 {
+                m.Age = age
+            }
+        }
+    }
+    if b, ok := raw["adopted"].(bool); ok {
+        m.Adopted = b
+    }
+    // Keep the dump's adoption date. Adoptions without one are stamped with
+    // the dump's own last change rather than the import time, so repeated
+    // runs leave them unchanged.
+    if t, ok := sourceTime(raw, "adoptedAt"); ok {
+        m.AdoptedAt = &t
+    } else if m.Adopted {
+        t, ok := sourceTime(raw, "updatedAt")
+        if !ok {
+            t = sourceCreatedAt(raw, time.Now().UTC())
+        }
+        m.AdoptedAt = &t
+    }
+    m.Image = firstString(raw, "image")
+    m.Owner = firstString(raw, "owner")
+    if loc, ok := raw["location"].(bson.M); ok {
+        gp := models.GeoPoint{Type: "Point"}
+        if t, ok := loc["type"].(string); ok && t != "" {
+            gp.Type = t
+        }
+        if coords, ok := loc["coordinates"].(bson.A); ok {
+            for _, c := range coords {
+                switch n := c.(type) {
+                case float64:
+                    gp.Coordinates = append(gp.Coordinates, n)
+                case int32:
+                    gp.Coordinates = append(gp.Coordinates, float64(n))
+                case int64:
+                    gp.Coordinates = append(gp.Coordinates, float64(n))
+                }
+            }
+        }
+        if len(gp.Coordinates) != 2 {
+            return m, errors.New("location needs [lng, lat] coordinates")
+        }
+        m.Location = &gp
+    }
+    return m, nil
+}
+
+// canonicalFields renders a model as the document fields an import may set.
+func canonicalFields(model interface{}) (bson.M, error) {
+    b, err := bson.Marshal(model)
+    if err != nil {
+        return nil, err
+    }
+    var out bson.M
+    if err := bson.Unmarshal(b, &out); err != nil {
+        return nil, err
+    }
+    for _, k := range []string{"_id", "createdAt", "updatedAt", "version"} {
+        delete(out, k)
+    }
+    return out, nil
+}
+
+// sameFields reports whether current already holds every canonical field.
+func sameFields(current, fields bson.M) bool {
+    for k, v := range fields {
+        if !reflect.DeepEqual(current[k], v) {
+            return false
+        }
+    }
+    return true
+}
+
+// updated returns current with fields set, as written by the import's update.
+func updated(current, fields bson.M) bson.M {
+    out := bson.M{}
+    for k, v := range current {
+        out[k] = v
+    }
+    for k, v := range fields {
+        out[k] = v
+    }
+    return out
+}
+
+// sourceCreatedAt keeps the original creation time: createdAt when the dump
+// has one, otherwise the timestamp of the source ObjectID.
+func sourceCreatedAt(raw bson.M, def time.Time) time.Time {
+    if t, ok := sourceTime(raw, "createdAt"); ok {
+        return t
+    }
+    if oid, ok := raw["_id"].(primitive.ObjectID); ok {
+        return oid.Timestamp().UTC()
+    }
+    return def
+}
+
+// sourceTime reads a date field of the dump, stored as a date or an RFC 3339 string.
+func sourceTime(raw bson.M, field string) (time.Time, bool) {
+    switch v := raw[field].(type) {
+    case primitive.DateTime:
+        return v.Time().UTC(), true
+    case time.Time:
+        return v.UTC(), true
+    case string:
+        if t, err := time.Parse(time.RFC3339, v); err == nil {
+            return t.UTC(), true
+        }
+    }
+    return time.Time{}, false
+}
+
+// refKey turns a source id or reference (ObjectID, or its hex string) into a mapping key.
+func refKey(v interface{}) (string, bool) {
+    switch id := v.(type) {
+    case primitive.ObjectID:
+        return id.Hex(), true
+    case string:
+        if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+            return oid.Hex(), true
+        }
+    }
+    return "", false
+}
+
+// refs collects the distinct id references held in field.
+func refs(docs []bson.M, field string) []string {
+    seen := map[string]bool{}
+    var out []string
+    for _, raw := range docs {
+        if k, ok := refKey(raw[field]); ok && !seen[k] {
+            seen[k] = true
+            out = append(out, k)
+        }
+    }
+    return out
+}
+
+func firstString(raw bson.M, keys ...string) string {
+    for _, k := range keys {
+        if s, ok := raw[k].(string); ok && strings.TrimSpace(s) != "" {
+            return strings.TrimSpace(s)
+        }
+    }
+    return ""
+}
+
+// ReadFile parses a dump, choosing BSON for .bson files and JSON otherwise.
+func ReadFile(name string, r io.Reader) ([]bson.M, error) {
+    if strings.EqualFold(filepath.Ext(name), ".bson") {
+        return ReadBSON(r)
+    }
+    return ReadJSON(r)
+}
+
+// ReadJSON parses mongoexport output: either a JSON array or one Extended
+// JSON document per line.
+func ReadJSON(r io.Reader) ([]bson.M, error) {
+    br := bufio.NewReader(r)
+    var raws []json.RawMessage
+    dec := json.NewDecoder(br)
+    if first, err := peekNonSpace(br); err != nil {
+        return nil, err
+    } else if first == '[' {
+        if err := dec.Decode(&raws); err != nil {
+            return nil, err
+        }
+    } else {
+        for {
+            var raw json.RawMessage
+            if err := dec.Decode(&raw); err == io.EOF {
+                break
+            } else if err != nil {
+                return nil, err
+            }
+            raws = append(raws, raw)
+        }
+    }
+    docs := make([]bson.M, 0, len(raws))
+    for i, raw := range raws {
+        var doc bson.M
+        if err := bson.UnmarshalExtJSON(raw, false, &doc); err != nil {
+            return nil, fmt.Errorf("document %d: %w", i, err)
+        }
+        docs = append(docs, doc)
+    }
+    return docs, nil
+}
+
+// ReadBSON parses mongodump output: concatenated BSON documents.
+func ReadBSON(r io.Reader) ([]bson.M, error) {
+    var docs []bson.M
+    var size [4]byte
+    for {
+        if _, err := io.ReadFull(r, size[:]); err == io.EOF {
+            return docs, nil
+        } else if err != nil {
+            return nil, err
+        }
+        n := int(binary.LittleEndian.Uint32(size[:]))
+        if n < 5 || n > 16*1024*1024 {
+            return nil, fmt.Errorf("document %d: invalid BSON length %d", len(docs), n)
+        }
+        buf := make([]byte, n)
+        copy(buf, size[:])
+        if _, err := io.ReadFull(r, buf[4:]); err != nil {
+            return nil, err
+        }
+        var doc bson.M
+        if err := bson.Unmarshal(buf, &doc); err != nil {
+            return nil, fmt.Errorf("document %d: %w", len(docs), err)
+        }
+        docs = append(docs, doc)
+    }
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+    for {
+        b, err := br.Peek(1)
+        if err == io.EOF {
+            return 0, nil
+        }
+        if err != nil {
+            return 0, err
+        }
+        if !bytes.ContainsAny(b, " \t\r\n") {
+            return b[0], nil
+        }
+        if _, err := br.ReadByte(); err != nil {
+            return 0, err
+        }
+    }
+}
@@ -0,0 +1,115 @@
+package dataset
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+    "go-api/pkg/models"
+)
+
+func TestCanonicalAnimalAdoptedAt(t *testing.T) {
+    adopted := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+    updated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+    id := primitive.NewObjectIDFromTimestamp(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+    tests := []struct {
+        name string
+        raw  bson.M
+        want *time.Time
+    }{
+        {"dump date", bson.M{"name": "Misu", "adopted": true, "adoptedAt": primitive.NewDateTimeFromTime(adopted)}, &adopted},
+        {"dump date as text", bson.M{"name": "Misu", "adopted": true, "adoptedAt": adopted.Format(time.RFC3339)}, &adopted},
+        {"stamped from updatedAt", bson.M{"name": "Misu", "adopted": true, "updatedAt": primitive.NewDateTimeFromTime(updated)}, &updated},
+        {"stamped from the id", bson.M{"_id": id, "name": "Misu", "adopted": true}, timePtr(id.Timestamp().UTC())},
+        {"undone adoption keeps its date", bson.M{"name": "Misu", "adopted": false, "adoptedAt": primitive.NewDateTimeFromTime(adopted)}, &adopted},
+        {"not adopted", bson.M{"name": "Misu", "updatedAt": primitive.NewDateTimeFromTime(updated)}, nil},
+    }
+    for _, tt := range tests {
+        m, err := canonicalAnimal(tt.raw)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if (m.AdoptedAt == nil) != (tt.want == nil) || m.AdoptedAt != nil && !m.AdoptedAt.Equal(*tt.want) {
+            t.Errorf("%s: adoptedAt = %v, want %v", tt.name, m.AdoptedAt, tt.want)
+        }
+    }
+}
+
+func timePtr(t time.Time) *time.Time {
+    return &t
+}
+
+// Created and updated documents are passed to the audit hook; unchanged ones
+// are not.
+func TestLoadAudits(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    mt.Run("categories", func(mt *mtest.T) {
+        ns := mt.DB.Name() + ".categories"
+        known, unchanged, added := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+        knownID, unchangedID, addedID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+        mapped := func(source, target primitive.ObjectID) bson.D {
+            return bson.D{{Key: "sourceId", Value: source.Hex()}, {Key: "targetId", Value: target}}
+        }
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, mapped(known, knownID), mapped(unchanged, unchangedID)),
+            mtest.CreateCursorResponse(0, ns, mtest.FirstBatch,
+                bson.D{{Key: "_id", Value: knownID}, {Key: "name", Value: "Mammal"}, {Key: "version", Value: int64(1)}},
+                bson.D{{Key: "_id", Value: unchangedID}, {Key: "name", Value: "Birds"}, {Key: "version", Value: int64(1)}}),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+            mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, mapped(added, addedID)),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+        )
+
+        type call struct {
+            action        string
+            id            primitive.ObjectID
+            before, after bson.M
+        }
+        var calls []call
+        im := NewImporter(mt.DB)
+        im.Audit = func(collection, action string, id primitive.ObjectID, before, after bson.M) {
+            if collection != "categories" {
+                t.Errorf("audited collection %q", collection)
+            }
+            calls = append(calls, call{action, id, before, after})
+        }
+        docs := []bson.M{
+            {"_id": known, "category_name": "Mammals"},
+            {"_id": unchanged, "name": "Birds"},
+            {"_id": added, "name": "Fish"},
+        }
+        var res Result
+        build := func(i int, raw bson.M) (interface{}, bool) {
+            return &models.Category{Name: firstString(raw, "name", "category_name")}, true
+        }
+        if _, err := im.load(context.Background(), "categories", docs, &res, build); err != nil {
+            t.Fatal(err)
+        }
+        if res.Created != 1 || res.Updated != 1 || res.Unchanged != 1 {
+            t.Fatalf("result %+v", res)
+        }
+        if len(calls) != 2 {
+            t.Fatalf("%d audit calls, want 2: %+v", len(calls), calls)
+        }
+        for _, c := range calls {
+            switch c.action {
+            case "update":
+                if c.id != knownID || c.before["name"] != "Mammal" || c.after["name"] != "Mammals" {
+                    t.Errorf("update audited as %+v", c)
+                }
+            case "create":
+                if c.id != addedID || c.before != nil || c.after["name"] != "Fish" {
+                    t.Errorf("create audited as %+v", c)
+                }
+            default:
+                t.Errorf("unexpected action %q", c.action)
+            }
+        }
+    })
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+    "context"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TextCollation orders text the Finnish way (å, ä and ö after z) and ignores
+// case but not accents. Lists sort text with it, so indexes on text sort keys
+// must be built with it too.
+var TextCollation = &options.Collation{Locale: "fi", Strength: 2}
+
+// RefSort stores on every document of From, under Key, the lower-cased name
+// of the document its Field references in Collection. Sorting by that name is
+// then an indexed sort on Key instead of a join per listed document.
+// References matching no document, such as legacy names, sort as themselves.
+type RefSort struct {
+    From, Field, Key string
+    Collection       string
+    NameFields       []string
+}
+
+var (
+    // SpeciesSort is the species name of each animal.
+    SpeciesSort = RefSort{From: "animals", Field: "species", Key: "speciesSort", Collection: "species", NameFields: []string{"name", "species_name"}}
+    // CategorySort is the category name of each species.
+    CategorySort = RefSort{From: "species", Field: "category", Key: "categorySort", Collection: "categories", NameFields: []string{"name", "category_name"}}
+    // SortKeys lists every RefSort.
+    SortKeys = []RefSort{SpeciesSort, CategorySort}
+)
+
+// Refresh recomputes Key on the documents of From matching filter. Call it
+// after writing those documents, or with a filter on Field after renaming or
+// deleting a referenced document.
+func (r RefSort) Refresh(ctx context.Context, database *mongo.Database, filter bson.M) error {
+    names := bson.A{}
+    for _, f := range r.NameFields {
+        names = append(names, bson.M{"$first": "$_ref." + f})
+    }
+    names = append(names, bson.M{"$toString": "$" + r.Field}, "")
+    pipeline := bson.A{
+        bson.M{"$match": filter},
+        // references are ObjectIDs or their hex; anything else stays as is
+        // and joins nothing
+        bson.M{"$project": bson.M{"_ref": bson.M{"$convert": bson.M{
+            "input":   "$" + r.Field,
+            "to":      "objectId",
+            "onError": "$" + r.Field,
+            "onNull":  nil,
+        }}, r.Field: 1}},
+        bson.M{"$lookup": bson.M{
+            "from":         r.Collection,
+            "localField":   "_ref",
+            "foreignField": "_id",
+            "as":           "_ref",
+        }},
+        bson.M{"$project": bson.M{r.Key: bson.M{"$toLower": bson.M{"$ifNull": names}}}},
+        bson.M{"$merge": bson.M{"into": r.From, "on": "_id", "whenMatched": "merge", "whenNotMatched": "discard"}},
+    }
+    cur, err := database.Collection(r.From).Aggregate(ctx, pipeline)
+    if err != nil {
+        return err
+    }
+    return cur.Close(ctx)
+}
+
+// RefreshIDs recomputes Key on the documents of From with the given ids.
+func (r RefSort) RefreshIDs(ctx context.Context, database *mongo.Database, ids ...interface{}) error {
+    if len(ids) == 0 {
+        return nil
+    }
+    return r.Refresh(ctx, database, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+// Backfill creates the index on Key and recomputes it on every document.
+func (r RefSort) Backfill(ctx context.Context, database *mongo.Database) error {
+    _, err := database.Collection(r.From).Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys:    bson.D{{Key: r.Key, Value: 1}, {Key: "_id", Value: 1}},
+        Options: options.Index().SetCollation(TextCollation),
+    })
+    if err != nil {
+        return err
+    }
+    return r.Refresh(ctx, database, bson.M{})
+}
+
+// BackfillSortKeys runs Backfill for each of SortKeys.
+func BackfillSortKeys(ctx context.Context, database *mongo.Database) error {
+    for _, r := range SortKeys {
+        if err := r.Backfill(ctx, database); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+    "crypto/subtle"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+    // RequestIDKey is the gin context key holding the current request ID.
+    RequestIDKey = "requestId"
+    // ActorKey is the gin context key an auth layer sets to the authenticated identity.
+    ActorKey = "actor"
+    // LenientQueryKey is the gin context key marking requests whose invalid query parameters are ignored.
+    LenientQueryKey = "lenientQuery"
+
+    RequestIDHeader = "X-Request-ID"
+    ActorHeader     = "X-Actor"
+    AdminHeader     = "X-Admin-Token"
+    LenientHeader   = "X-Lenient-Query"
+)
+
+// RequestID reuses an incoming X-Request-ID or generates one, stores it in the
+// context and echoes it back on the response.
+func RequestID() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id := strings.TrimSpace(c.GetHeader(RequestIDHeader))
+        if id == "" {
+            id = primitive.NewObjectID().Hex()
+        }
+        c.Set(RequestIDKey, id)
+        c.Header(RequestIDHeader, id)
+        c.Next()
+    }
+}
+
+// AdminOnly guards admin endpoints with a shared token. It fails closed:
+// when no token is configured the endpoints answer 503.
+func AdminOnly(token string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if token == "" {
+            c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "admin endpoints are disabled: ADMIN_TOKEN is not set"})
+            return
+        }
+        got := c.GetHeader(AdminHeader)
+        if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+            c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin token required"})
+            return
+        }
+        c.Next()
+    }
+}
+
+// LenientQuery marks requests for lenient query parameter parsing: all of
+// them when always is set, otherwise those sending X-Lenient-Query: true.
+// Old clients rely on invalid values silently falling back to defaults.
+func LenientQuery(always bool) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        lenient, _ := strconv.ParseBool(c.GetHeader(LenientHeader))
+        c.Set(LenientQueryKey, always || lenient)
+        c.Next()
+    }
+}
+
+// IsLenientQuery reports whether LenientQuery marked the request.
+func IsLenientQuery(c *gin.Context) bool {
+    return c.GetBool(LenientQueryKey)
+}
+
+// GetRequestID returns the request ID assigned by RequestID, if any.
+func GetRequestID(c *gin.Context) string {
+    return c.GetString(RequestIDKey)
+}
+
+// GetActor returns who performed the request: the auth identity when one is
+// set on the context, otherwise the X-Actor header, otherwise "anonymous".
+func GetActor(c *gin.Context) string {
+    if a := c.GetString(ActorKey); a != "" {
+        return a
+    }
+    if a := strings.TrimSpace(c.GetHeader(ActorHeader)); a != "" {
+        return a
+    }
+    return "anonymous"
+}
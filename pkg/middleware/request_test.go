@@ -0,0 +1,52 @@
+package middleware
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+)
+
+func TestAdminOnly(t *testing.T) {
+    tests := []struct {
+        name, token, header string
+        want                int
+    }{
+        {"no token configured", "", "", http.StatusServiceUnavailable},
+        {"no token configured, header sent", "", "anything", http.StatusServiceUnavailable},
+        {"missing header", "s3cret", "", http.StatusForbidden},
+        {"wrong header", "s3cret", "s3cre", http.StatusForbidden},
+        {"matching header", "s3cret", "s3cret", http.StatusOK},
+    }
+    gin.SetMode(gin.TestMode)
+    for _, tt := range tests {
+        r := gin.New()
+        r.GET("/audit", AdminOnly(tt.token), func(c *gin.Context) { c.Status(http.StatusOK) })
+        req := httptest.NewRequest("GET", "/audit", nil)
+        if tt.header != "" {
+            req.Header.Set(AdminHeader, tt.header)
+        }
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        if w.Code != tt.want {
+            t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+        }
+    }
+}
+
+func TestGetActor(t *testing.T) {
+    c, _ := gin.CreateTestContext(httptest.NewRecorder())
+    c.Request = httptest.NewRequest("GET", "/", nil)
+    if got := GetActor(c); got != "anonymous" {
+        t.Errorf("without identity: %q, want anonymous", got)
+    }
+    c.Request.Header.Set(ActorHeader, " bob ")
+    if got := GetActor(c); got != "bob" {
+        t.Errorf("from header: %q, want bob", got)
+    }
+    c.Set(ActorKey, "alice")
+    if got := GetActor(c); got != "alice" {
+        t.Errorf("auth identity: %q, want alice", got)
+    }
+}
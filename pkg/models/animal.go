@@ -20,8 +20,11 @@ type Animal struct {
     Image     string             `bson:"image,omitempty" json:"image,omitempty"`
     Owner     string             `bson:"owner,omitempty" json:"owner,omitempty"`
     Location  *GeoPoint          `bson:"location,omitempty" json:"location,omitempty"`
+    // AdoptedAt is when the animal was last adopted; it is kept when an adoption is undone.
+    AdoptedAt *time.Time         `bson:"adoptedAt,omitempty" json:"adoptedAt,omitempty"`
     CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
     UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+    Version   int64              `bson:"version" json:"version"`
 }
 
 type Pagination struct {
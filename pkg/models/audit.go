@@ -0,0 +1,26 @@
+package models
+
+import (
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FieldChange is a single top-level field that differs between the
+// document before and after a mutation. Missing values are null.
+type FieldChange struct {
+    Field  string      `bson:"field" json:"field"`
+    Before interface{} `bson:"before" json:"before"`
+    After  interface{} `bson:"after" json:"after"`
+}
+
+type AuditEntry struct {
+    ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+    Collection string             `bson:"collection" json:"collection"`
+    DocumentID primitive.ObjectID `bson:"documentId" json:"documentId"`
+    Action     string             `bson:"action" json:"action"` // create, update or delete
+    Actor      string             `bson:"actor" json:"actor"`
+    RequestID  string             `bson:"requestId" json:"requestId"`
+    Changes    []FieldChange      `bson:"changes" json:"changes"`
+    Timestamp  time.Time          `bson:"timestamp" json:"timestamp"`
+}
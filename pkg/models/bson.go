@@ -0,0 +1,243 @@
+package models
+
+import (
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/bsontype"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+
+    "go-api/pkg/utils"
+)
+
+// The UnmarshalBSON methods read stored documents directly, element by
+// element, instead of going through a bson.M. They accept the shapes older
+// documents were written in: the animal_name, species_name and
+// category_name aliases, ages and versions stored as any number type, a
+// birthdate instead of an age, references stored as ObjectIDs and dates
+// stored as strings. Missing createdAt falls back to the ObjectID timestamp
+// and missing updatedAt to createdAt. Values of unexpected types are
+// skipped rather than failing the whole document.
+
+func (a *Animal) UnmarshalBSON(data []byte) error {
+    elems, err := bson.Raw(data).Elements()
+    if err != nil {
+        return err
+    }
+    *a = Animal{}
+    var alias string
+    hasAge := false
+    birthAge := -1
+    for _, e := range elems {
+        v := e.Value()
+        switch e.Key() {
+        case "_id":
+            a.ID, _ = v.ObjectIDOK()
+        case "name":
+            a.Name, _ = v.StringValueOK()
+        case "animal_name":
+            alias, _ = v.StringValueOK()
+        case "species":
+            a.Species = refString(v)
+        case "age":
+            if n, ok := rawInt(v); ok {
+                a.Age, hasAge = int(n), true
+            }
+        case "birthdate":
+            birthAge = rawAge(v)
+        case "adopted":
+            a.Adopted, _ = v.BooleanOK()
+        case "image":
+            a.Image, _ = v.StringValueOK()
+        case "owner":
+            a.Owner, _ = v.StringValueOK()
+        case "location":
+            if doc, ok := v.DocumentOK(); ok {
+                var gp GeoPoint
+                if err := gp.UnmarshalBSON(doc); err == nil {
+                    a.Location = &gp
+                }
+            }
+        case "adoptedAt":
+            if t, ok := rawTime(v); ok {
+                a.AdoptedAt = &t
+            }
+        case "createdAt":
+            a.CreatedAt, _ = rawTime(v)
+        case "updatedAt":
+            a.UpdatedAt, _ = rawTime(v)
+        case "version":
+            a.Version, _ = rawInt(v)
+        }
+    }
+    if a.Name == "" {
+        a.Name = alias
+    }
+    if !hasAge && birthAge > 0 {
+        a.Age = birthAge
+    }
+    fillTimes(a.ID, &a.CreatedAt, &a.UpdatedAt)
+    return nil
+}
+
+func (g *GeoPoint) UnmarshalBSON(data []byte) error {
+    elems, err := bson.Raw(data).Elements()
+    if err != nil {
+        return err
+    }
+    *g = GeoPoint{}
+    for _, e := range elems {
+        v := e.Value()
+        switch e.Key() {
+        case "type":
+            g.Type, _ = v.StringValueOK()
+        case "coordinates":
+            arr, ok := v.ArrayOK()
+            if !ok {
+                continue
+            }
+            vals, err := arr.Values()
+            if err != nil {
+                return err
+            }
+            g.Coordinates = make([]float64, 0, len(vals))
+            for _, cv := range vals {
+                if f, ok := rawFloat(cv); ok {
+                    g.Coordinates = append(g.Coordinates, f)
+                }
+            }
+        }
+    }
+    return nil
+}
+
+func (s *Species) UnmarshalBSON(data []byte) error {
+    elems, err := bson.Raw(data).Elements()
+    if err != nil {
+        return err
+    }
+    *s = Species{}
+    var alias string
+    for _, e := range elems {
+        v := e.Value()
+        switch e.Key() {
+        case "_id":
+            s.ID, _ = v.ObjectIDOK()
+        case "name":
+            s.Name, _ = v.StringValueOK()
+        case "species_name":
+            alias, _ = v.StringValueOK()
+        case "category":
+            s.Category = refString(v)
+        case "createdAt":
+            s.CreatedAt, _ = rawTime(v)
+        case "updatedAt":
+            s.UpdatedAt, _ = rawTime(v)
+        case "version":
+            s.Version, _ = rawInt(v)
+        }
+    }
+    if s.Name == "" {
+        s.Name = alias
+    }
+    fillTimes(s.ID, &s.CreatedAt, &s.UpdatedAt)
+    return nil
+}
+
+func (c *Category) UnmarshalBSON(data []byte) error {
+    elems, err := bson.Raw(data).Elements()
+    if err != nil {
+        return err
+    }
+    *c = Category{}
+    var alias string
+    for _, e := range elems {
+        v := e.Value()
+        switch e.Key() {
+        case "_id":
+            c.ID, _ = v.ObjectIDOK()
+        case "name":
+            c.Name, _ = v.StringValueOK()
+        case "category_name":
+            alias, _ = v.StringValueOK()
+        case "createdAt":
+            c.CreatedAt, _ = rawTime(v)
+        case "updatedAt":
+            c.UpdatedAt, _ = rawTime(v)
+        case "version":
+            c.Version, _ = rawInt(v)
+        }
+    }
+    if c.Name == "" {
+        c.Name = alias
+    }
+    fillTimes(c.ID, &c.CreatedAt, &c.UpdatedAt)
+    return nil
+}
+
+// fillTimes applies the createdAt and updatedAt fallbacks for legacy docs.
+func fillTimes(id primitive.ObjectID, created, updated *time.Time) {
+    if created.IsZero() && id != primitive.NilObjectID {
+        *created = id.Timestamp()
+    }
+    if updated.IsZero() {
+        *updated = *created
+    }
+}
+
+// refString reads a reference stored as a string or an ObjectID.
+func refString(v bson.RawValue) string {
+    if oid, ok := v.ObjectIDOK(); ok {
+        return oid.Hex()
+    }
+    s, _ := v.StringValueOK()
+    return s
+}
+
+func rawInt(v bson.RawValue) (int64, bool) {
+    switch v.Type {
+    case bsontype.Int32:
+        return int64(v.Int32()), true
+    case bsontype.Int64:
+        return v.Int64(), true
+    case bsontype.Double:
+        return int64(v.Double()), true
+    }
+    return 0, false
+}
+
+func rawFloat(v bson.RawValue) (float64, bool) {
+    switch v.Type {
+    case bsontype.Double:
+        return v.Double(), true
+    case bsontype.Int32:
+        return float64(v.Int32()), true
+    case bsontype.Int64:
+        return float64(v.Int64()), true
+    }
+    return 0, false
+}
+
+// rawTime reads a BSON date, or an RFC 3339 string as older imports wrote.
+func rawTime(v bson.RawValue) (time.Time, bool) {
+    switch v.Type {
+    case bsontype.DateTime:
+        return v.Time().UTC(), true
+    case bsontype.String:
+        t, err := time.Parse(time.RFC3339, v.StringValue())
+        return t, err == nil
+    }
+    return time.Time{}, false
+}
+
+// rawAge is the age in years for a birthdate stored as a date or as
+// YYYY-MM-DD, or -1.
+func rawAge(v bson.RawValue) int {
+    switch v.Type {
+    case bsontype.DateTime:
+        return utils.AgeFromTime(v.Time())
+    case bsontype.String:
+        return utils.AgeFromBirthdate(v.StringValue())
+    }
+    return -1
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+    "reflect"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func mustMarshal(t *testing.T, doc interface{}) bson.Raw {
+    t.Helper()
+    b, err := bson.Marshal(doc)
+    if err != nil {
+        t.Fatalf("marshal %v: %v", doc, err)
+    }
+    return b
+}
+
+func TestAnimalUnmarshalBSON(t *testing.T) {
+    id := primitive.NewObjectIDFromTimestamp(time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC))
+    speciesID := primitive.NewObjectID()
+    created := time.Date(2024, 1, 2, 3, 4, 5, 6e6, time.UTC)
+    updated := created.Add(time.Hour)
+    adopted := created.Add(48 * time.Hour)
+    // three years and a month ago, whatever the day the test runs
+    born := time.Now().UTC().AddDate(-3, -1, 0)
+
+    tests := []struct {
+        name string
+        doc  bson.M
+        want Animal
+    }{
+        {
+            name: "current shape",
+            doc: bson.M{
+                "_id": id, "name": "Misu", "species": speciesID.Hex(), "age": int32(4), "adopted": true,
+                "owner": "Anna", "image": "misu.jpg", "location": bson.M{"type": "Point", "coordinates": bson.A{24.94, 60.17}},
+                "adoptedAt": primitive.NewDateTimeFromTime(adopted), "createdAt": primitive.NewDateTimeFromTime(created),
+                "updatedAt": primitive.NewDateTimeFromTime(updated), "version": int64(3),
+            },
+            want: Animal{
+                ID: id, Name: "Misu", Species: speciesID.Hex(), Age: 4, Adopted: true, Owner: "Anna", Image: "misu.jpg",
+                Location:  &GeoPoint{Type: "Point", Coordinates: []float64{24.94, 60.17}},
+                AdoptedAt: &adopted, CreatedAt: created, UpdatedAt: updated, Version: 3,
+            },
+        },
+        {
+            name: "animal_name alias",
+            doc:  bson.M{"_id": id, "animal_name": "Rekku", "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, Name: "Rekku", CreatedAt: created, UpdatedAt: created},
+        },
+        {
+            name: "name wins over animal_name",
+            doc:  bson.M{"_id": id, "name": "Misu", "animal_name": "Rekku", "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, Name: "Misu", CreatedAt: created, UpdatedAt: created},
+        },
+        {
+            name: "int64 age",
+            doc:  bson.M{"_id": id, "age": int64(7), "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, Age: 7, CreatedAt: created, UpdatedAt: created},
+        },
+        {
+            name: "double age",
+            doc:  bson.M{"_id": id, "age": 2.9, "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, Age: 2, CreatedAt: created, UpdatedAt: created},
+        },
+        {
+            name: "age of another type is skipped",
+            doc:  bson.M{"_id": id, "age": "five", "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, CreatedAt: created, UpdatedAt: created},
+        },
+        {
+            name: "age from string birthdate",
+            doc:  bson.M{"_id": id, "birthdate": born.Format("2006-01-02"), "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, Age: 3, CreatedAt: created, UpdatedAt: created},
+        },
+        {
+            name: "age from date birthdate",
+            doc:  bson.M{"_id": id, "birthdate": primitive.NewDateTimeFromTime(born), "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, Age: 3, CreatedAt: created, UpdatedAt: created},
+        },
+        {
+            name: "stored age wins over birthdate",
+            doc:  bson.M{"_id": id, "age": int32(0), "birthdate": born.Format("2006-01-02"), "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, Age: 0, CreatedAt: created, UpdatedAt: created},
+        },
+        {
+            name: "unparseable birthdate",
+            doc:  bson.M{"_id": id, "birthdate": "someday", "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, CreatedAt: created, UpdatedAt: created},
+        },
+        {
+            name: "ObjectID species reference",
+            doc:  bson.M{"_id": id, "species": speciesID, "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, Species: speciesID.Hex(), CreatedAt: created, UpdatedAt: created},
+        },
+        {
+            name: "RFC 3339 string dates",
+            doc: bson.M{"_id": id, "createdAt": created.Format(time.RFC3339Nano), "updatedAt": updated.Format(time.RFC3339Nano),
+                "adoptedAt": adopted.Format(time.RFC3339Nano)},
+            want: Animal{ID: id, AdoptedAt: &adopted, CreatedAt: created, UpdatedAt: updated},
+        },
+        {
+            name: "createdAt from the ObjectID, updatedAt from createdAt",
+            doc:  bson.M{"_id": id, "name": "Misu", "version": int32(2)},
+            want: Animal{ID: id, Name: "Misu", CreatedAt: id.Timestamp(), UpdatedAt: id.Timestamp(), Version: 2},
+        },
+        {
+            name: "integer coordinates",
+            doc:  bson.M{"_id": id, "location": bson.M{"type": "Point", "coordinates": bson.A{int32(25), int64(60)}}, "createdAt": primitive.NewDateTimeFromTime(created)},
+            want: Animal{ID: id, Location: &GeoPoint{Type: "Point", Coordinates: []float64{25, 60}}, CreatedAt: created, UpdatedAt: created},
+        },
+    }
+    for _, tt := range tests {
+        var got Animal
+        if err := got.UnmarshalBSON(mustMarshal(t, tt.doc)); err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestSpeciesUnmarshalBSON(t *testing.T) {
+    id := primitive.NewObjectID()
+    categoryID := primitive.NewObjectID()
+    created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+    tests := []struct {
+        name string
+        doc  bson.M
+        want Species
+    }{
+        {
+            name: "species_name alias and ObjectID category",
+            doc:  bson.M{"_id": id, "species_name": "Kissa", "category": categoryID, "createdAt": primitive.NewDateTimeFromTime(created), "version": 2.0},
+            want: Species{ID: id, Name: "Kissa", Category: categoryID.Hex(), CreatedAt: created, UpdatedAt: created, Version: 2},
+        },
+        {
+            name: "name wins, legacy category name",
+            doc:  bson.M{"_id": id, "name": "Cat", "species_name": "Kissa", "category": "Mammals"},
+            want: Species{ID: id, Name: "Cat", Category: "Mammals", CreatedAt: id.Timestamp(), UpdatedAt: id.Timestamp()},
+        },
+    }
+    for _, tt := range tests {
+        var got Species
+        if err := got.UnmarshalBSON(mustMarshal(t, tt.doc)); err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestCategoryUnmarshalBSON(t *testing.T) {
+    id := primitive.NewObjectID()
+    created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+    updated := created.Add(time.Minute)
+    tests := []struct {
+        name string
+        doc  bson.M
+        want Category
+    }{
+        {
+            name: "category_name alias",
+            doc:  bson.M{"_id": id, "category_name": "Nisäkkäät", "createdAt": primitive.NewDateTimeFromTime(created), "updatedAt": primitive.NewDateTimeFromTime(updated), "version": int32(5)},
+            want: Category{ID: id, Name: "Nisäkkäät", CreatedAt: created, UpdatedAt: updated, Version: 5},
+        },
+        {
+            name: "timestamps from the ObjectID",
+            doc:  bson.M{"_id": id, "name": "Birds"},
+            want: Category{ID: id, Name: "Birds", CreatedAt: id.Timestamp(), UpdatedAt: id.Timestamp()},
+        },
+    }
+    for _, tt := range tests {
+        var got Category
+        if err := got.UnmarshalBSON(mustMarshal(t, tt.doc)); err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestUnmarshalBSONMalformed(t *testing.T) {
+    var a Animal
+    if err := a.UnmarshalBSON([]byte{5, 0, 0}); err == nil {
+        t.Error("Animal.UnmarshalBSON accepted a truncated document")
+    }
+}
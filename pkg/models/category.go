@@ -10,4 +10,5 @@ type Category struct {
     Name      string             `bson:"name" json:"name" validate:"required,min=2,max=100"`
     CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
     UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+    Version   int64              `bson:"version" json:"version"`
 }
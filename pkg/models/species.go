@@ -11,4 +11,5 @@ type Species struct {
     Category  string             `bson:"category" json:"category" validate:"omitempty"`
     CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
     UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+    Version   int64              `bson:"version" json:"version"`
 }
@@ -0,0 +1,262 @@
+package query
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Type is the value type of a filterable field.
+type Type int
+
+const (
+    String Type = iota
+    Int
+    Float
+    Bool
+    Time
+    // Ref is a reference to another document, stored either as an ObjectID
+    // or as its hex string (or, for legacy data, a plain name).
+    Ref
+)
+
+func (t Type) String() string {
+    switch t {
+    case Int:
+        return "integer"
+    case Float:
+        return "number"
+    case Bool:
+        return "boolean"
+    case Time:
+        return "date"
+    case Ref:
+        return "reference"
+    }
+    return "string"
+}
+
+// Field describes a filterable field. Paths are the document fields holding
+// it; more than one means aliases (e.g. name and animal_name), any of which
+// may match.
+type Field struct {
+    Type  Type
+    Paths []string
+    // Resolve optionally maps a Ref value that is not an id (e.g. a species
+    // name) to the ids it stands for; they are matched besides the value itself.
+    Resolve func(value string) (bson.A, error)
+}
+
+// Fields is the whitelist of fields a resource can be filtered on, by the
+// name used in filter expressions.
+type Fields map[string]Field
+
+// FieldError reports a filter that is well-formed but refers to an unknown
+// field or uses a value or operator the field does not support.
+type FieldError struct {
+    Pos int
+    Msg string
+}
+
+func (e *FieldError) Error() string {
+    return fmt.Sprintf("filter: %s at position %d", e.Msg, e.Pos)
+}
+
+// CompileString parses s and compiles it against fields.
+func CompileString(s string, fields Fields) (bson.M, error) {
+    e, err := Parse(s)
+    if err != nil {
+        return nil, err
+    }
+    return Compile(e, fields)
+}
+
+// Compile turns an expression into a MongoDB filter.
+func Compile(e Expr, fields Fields) (bson.M, error) {
+    switch n := e.(type) {
+    case And:
+        parts, err := compileAll(n.Operands, fields)
+        if err != nil {
+            return nil, err
+        }
+        return bson.M{"$and": parts}, nil
+    case Or:
+        parts, err := compileAll(n.Operands, fields)
+        if err != nil {
+            return nil, err
+        }
+        return bson.M{"$or": parts}, nil
+    case Not:
+        inner, err := Compile(n.Operand, fields)
+        if err != nil {
+            return nil, err
+        }
+        return bson.M{"$nor": bson.A{inner}}, nil
+    case Truthy:
+        f, err := lookup(fields, n.Field, n.Pos)
+        if err != nil {
+            return nil, err
+        }
+        if f.Type != Bool {
+            return nil, &FieldError{n.Pos, fmt.Sprintf("field %q is a %s; compare it with a value", n.Field, f.Type)}
+        }
+        return anyPath(f.Paths, true), nil
+    case Compare:
+        return compileCompare(n, fields)
+    }
+    return nil, fmt.Errorf("filter: unknown node %T", e)
+}
+
+func compileAll(es []Expr, fields Fields) (bson.A, error) {
+    out := make(bson.A, 0, len(es))
+    for _, e := range es {
+        m, err := Compile(e, fields)
+        if err != nil {
+            return nil, err
+        }
+        out = append(out, m)
+    }
+    return out, nil
+}
+
+func compileCompare(n Compare, fields Fields) (bson.M, error) {
+    f, err := lookup(fields, n.Field, n.Pos)
+    if err != nil {
+        return nil, err
+    }
+    switch n.Op {
+    case "~":
+        if f.Type != String {
+            return nil, &FieldError{n.Pos, fmt.Sprintf("~ needs a string field, %q is a %s", n.Field, f.Type)}
+        }
+        return anyPath(f.Paths, bson.M{"$regex": regexp.QuoteMeta(n.Values[0]), "$options": "i"}), nil
+    case ">", ">=", "<", "<=":
+        if f.Type == Bool || f.Type == Ref {
+            return nil, &FieldError{n.Pos, fmt.Sprintf("%s cannot be used with %s field %q", n.Op, f.Type, n.Field)}
+        }
+    }
+
+    var values bson.A
+    for _, raw := range n.Values {
+        vs, err := convert(f.Type, raw)
+        if err != nil {
+            return nil, &FieldError{n.Pos, fmt.Sprintf("field %q: %v", n.Field, err)}
+        }
+        values = append(values, vs...)
+        if f.Type == Ref && len(vs) == 1 && f.Resolve != nil {
+            ids, err := f.Resolve(raw)
+            if err != nil {
+                return nil, err
+            }
+            values = append(values, ids...)
+        }
+    }
+    var cond interface{}
+    switch n.Op {
+    case "=":
+        if len(values) == 1 {
+            cond = values[0]
+        } else {
+            cond = bson.M{"$in": values}
+        }
+    case "in":
+        cond = bson.M{"$in": values}
+    case "!=":
+        // Negate the whole condition, so a document matches only when none
+        // of the aliases holds the value.
+        return bson.M{"$nor": bson.A{anyPath(f.Paths, bson.M{"$in": values})}}, nil
+    case ">":
+        cond = bson.M{"$gt": values[0]}
+    case ">=":
+        cond = bson.M{"$gte": values[0]}
+    case "<":
+        cond = bson.M{"$lt": values[0]}
+    case "<=":
+        cond = bson.M{"$lte": values[0]}
+    default:
+        return nil, &FieldError{n.Pos, fmt.Sprintf("unknown operator %q", n.Op)}
+    }
+    return anyPath(f.Paths, cond), nil
+}
+
+func lookup(fields Fields, name string, pos int) (Field, error) {
+    if f, ok := fields[name]; ok {
+        return f, nil
+    }
+    return Field{}, &FieldError{pos, fmt.Sprintf("unknown field %q (allowed: %s)", name, strings.Join(fields.Names(), ", "))}
+}
+
+// Names returns the field names in a stable order.
+func (fs Fields) Names() []string {
+    names := make([]string, 0, len(fs))
+    for n := range fs {
+        names = append(names, n)
+    }
+    sort.Strings(names)
+    return names
+}
+
+func anyPath(paths []string, cond interface{}) bson.M {
+    if len(paths) == 1 {
+        return bson.M{paths[0]: cond}
+    }
+    ors := make(bson.A, 0, len(paths))
+    for _, p := range paths {
+        ors = append(ors, bson.M{p: cond})
+    }
+    return bson.M{"$or": ors}
+}
+
+// convert parses a raw value for a field type. A Ref yields both the string
+// and, when it is valid hex, the ObjectID, since both forms are stored.
+func convert(t Type, raw string) (bson.A, error) {
+    switch t {
+    case Int:
+        v, err := strconv.Atoi(raw)
+        if err != nil {
+            return nil, fmt.Errorf("%q is not an integer", raw)
+        }
+        return bson.A{v}, nil
+    case Float:
+        v, err := strconv.ParseFloat(raw, 64)
+        if err != nil {
+            return nil, fmt.Errorf("%q is not a number", raw)
+        }
+        return bson.A{v}, nil
+    case Bool:
+        v, err := strconv.ParseBool(raw)
+        if err != nil {
+            return nil, fmt.Errorf("%q is not true or false", raw)
+        }
+        return bson.A{v}, nil
+    case Time:
+        v, err := ParseTime(raw)
+        if err != nil {
+            return nil, err
+        }
+        return bson.A{v}, nil
+    case Ref:
+        if oid, err := primitive.ObjectIDFromHex(raw); err == nil {
+            return bson.A{raw, oid}, nil
+        }
+        return bson.A{raw}, nil
+    }
+    return bson.A{raw}, nil
+}
+
+// ParseTime accepts RFC 3339 timestamps and YYYY-MM-DD dates (UTC midnight).
+func ParseTime(raw string) (time.Time, error) {
+    if t, err := time.Parse(time.RFC3339, raw); err == nil {
+        return t.UTC(), nil
+    }
+    if t, err := time.Parse("2006-01-02", raw); err == nil {
+        return t, nil
+    }
+    return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time or YYYY-MM-DD date", raw)
+}
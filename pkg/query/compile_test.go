@@ -0,0 +1,167 @@
+package query
+
+import (
+    "errors"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testFields = Fields{
+    "name":      {Type: String, Paths: []string{"name", "animal_name"}},
+    "owner":     {Type: String, Paths: []string{"owner"}},
+    "age":       {Type: Int, Paths: []string{"age"}},
+    "weight":    {Type: Float, Paths: []string{"weight"}},
+    "adopted":   {Type: Bool, Paths: []string{"adopted"}},
+    "createdAt": {Type: Time, Paths: []string{"createdAt"}},
+    "species":   {Type: Ref, Paths: []string{"species"}},
+}
+
+func TestCompile(t *testing.T) {
+    oid := primitive.NewObjectID()
+    tests := []struct {
+        in   string
+        want bson.M
+    }{
+        {`age>=2 and (species=cat or species=dog) and not adopted`, bson.M{"$and": bson.A{
+            bson.M{"age": bson.M{"$gte": 2}},
+            bson.M{"$or": bson.A{bson.M{"species": "cat"}, bson.M{"species": "dog"}}},
+            bson.M{"$nor": bson.A{bson.M{"adopted": true}}},
+        }}},
+        {`adopted`, bson.M{"adopted": true}},
+        {`adopted=false`, bson.M{"adopted": false}},
+        {`age=3`, bson.M{"age": 3}},
+        {`age<-1`, bson.M{"age": bson.M{"$lt": -1}}},
+        {`weight<=2.5`, bson.M{"weight": bson.M{"$lte": 2.5}}},
+        {`createdAt>2024-01-02`, bson.M{"createdAt": bson.M{"$gt": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}}},
+        {`createdAt<2024-01-02T12:00:00+02:00`, bson.M{"createdAt": bson.M{"$lt": time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}}},
+        // aliases: any of the paths may match
+        {`name="Black Jack"`, bson.M{"$or": bson.A{bson.M{"name": "Black Jack"}, bson.M{"animal_name": "Black Jack"}}}},
+        // != matches only when no alias holds the value
+        {`owner!=Anna`, bson.M{"$nor": bson.A{bson.M{"owner": bson.M{"$in": bson.A{"Anna"}}}}}},
+        {`age in (1, 2)`, bson.M{"age": bson.M{"$in": bson.A{1, 2}}}},
+        // references match both stored forms of an id
+        {`species=` + oid.Hex(), bson.M{"species": bson.M{"$in": bson.A{oid.Hex(), oid}}}},
+        // ~ is a case-insensitive substring match, not a pattern
+        {`owner~"a.b*"`, bson.M{"owner": bson.M{"$regex": `a\.b\*`, "$options": "i"}}},
+    }
+    for _, tt := range tests {
+        got, err := CompileString(tt.in, testFields)
+        if err != nil {
+            t.Errorf("CompileString(%q): %v", tt.in, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("CompileString(%q)\n got %v\nwant %v", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestCompileResolve(t *testing.T) {
+    oid := primitive.NewObjectID()
+    fields := Fields{"species": {Type: Ref, Paths: []string{"species"}, Resolve: func(v string) (bson.A, error) {
+        if v == "cat" {
+            return bson.A{oid}, nil
+        }
+        return nil, nil
+    }}}
+    got, err := CompileString(`species=cat`, fields)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := bson.M{"species": bson.M{"$in": bson.A{"cat", oid}}}
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("got %v, want %v", got, want)
+    }
+}
+
+func TestCompileFieldErrors(t *testing.T) {
+    tests := []struct {
+        in  string
+        pos int
+        msg string
+    }{
+        // not on the whitelist
+        {`password=x`, 0, `unknown field "password" (allowed: adopted, age, createdAt, name, owner, species, weight)`},
+        {`age>1 and (owner=Anna or _id=1)`, 25, `unknown field "_id"`},
+        {`not secret`, 4, `unknown field "secret"`},
+        {`age=two`, 0, `"two" is not an integer`},
+        {`weight>heavy`, 0, `"heavy" is not a number`},
+        {`adopted=yes`, 0, `"yes" is not true or false`},
+        {`createdAt>yesterday`, 0, `is not an RFC 3339 time`},
+        {`owner`, 0, `field "owner" is a string`},
+        {`age~1`, 0, "~ needs a string field"},
+        {`adopted>true`, 0, "> cannot be used with boolean field"},
+        {`species<x`, 0, "< cannot be used with reference field"},
+    }
+    for _, tt := range tests {
+        _, err := CompileString(tt.in, testFields)
+        var fe *FieldError
+        if !errors.As(err, &fe) {
+            t.Errorf("CompileString(%q) = %v, want a FieldError", tt.in, err)
+            continue
+        }
+        if fe.Pos != tt.pos || !strings.Contains(fe.Msg, tt.msg) {
+            t.Errorf("CompileString(%q) = %q at %d, want %q at %d", tt.in, fe.Msg, fe.Pos, tt.msg, tt.pos)
+        }
+    }
+}
+
+// operatorKeys returns every key starting with "$" in a compiled filter.
+func operatorKeys(v interface{}, into map[string]bool) {
+    switch n := v.(type) {
+    case bson.M:
+        for k, sub := range n {
+            if strings.HasPrefix(k, "$") {
+                into[k] = true
+            }
+            operatorKeys(sub, into)
+        }
+    case bson.A:
+        for _, sub := range n {
+            operatorKeys(sub, into)
+        }
+    }
+}
+
+// User input only ever becomes field values; the operators in the output are
+// the compiler's own.
+func TestCompileEmitsNoUserOperators(t *testing.T) {
+    allowed := map[string]bool{
+        "$and": true, "$or": true, "$nor": true, "$in": true, "$gt": true, "$gte": true,
+        "$lt": true, "$lte": true, "$regex": true, "$options": true,
+    }
+    inputs := []string{
+        `name="$where"`,
+        `name='{"$where": "sleep(1000)"}'`,
+        `owner~"$expr"`,
+        `name in ("$function", "$expr", "$where")`,
+        `not (owner="$ne" or name!="$gt")`,
+        `species="{\"$expr\": {\"$eq\": [1, 1]}}"`,
+        `name~".*"`,
+    }
+    for _, in := range inputs {
+        got, err := CompileString(in, testFields)
+        if err != nil {
+            t.Errorf("CompileString(%q): %v", in, err)
+            continue
+        }
+        keys := map[string]bool{}
+        operatorKeys(got, keys)
+        for k := range keys {
+            if !allowed[k] {
+                t.Errorf("CompileString(%q) emitted %s: %v", in, k, got)
+            }
+        }
+    }
+    // operators cannot be spelled as fields either
+    for _, in := range []string{`$where=1`, `$expr`, `name.$where=1`} {
+        if _, err := CompileString(in, testFields); err == nil {
+            t.Errorf("CompileString(%q) was accepted", in)
+        }
+    }
+}
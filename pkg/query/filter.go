@@ -0,0 +1,343 @@
+// Package query parses the filter= expression language of the list endpoints
+// and compiles it to MongoDB filters.
+//
+// Grammar (keywords are case-insensitive):
+//
+//  expr       = or
+//  or         = and { "or" and }
+//  and        = unary { "and" unary }
+//  unary      = "not" unary | primary
+//  primary    = "(" expr ")" | field [ op value | "in" "(" value { "," value } ")" ]
+//  op         = "=" | "!=" | ">" | ">=" | "<" | "<=" | "~"
+//  value      = word | "quoted string" | 'quoted string'
+//
+// A field on its own is true when it is a boolean field set to true, so
+// `not adopted` reads naturally. `~` is a case-insensitive substring match.
+package query
+
+import (
+    "fmt"
+    "strings"
+)
+
+// maxFilterLength and maxDepth bound the work a single filter can cause.
+const (
+    maxFilterLength = 2000
+    maxDepth        = 32
+)
+
+// Expr is a node of a parsed filter.
+type Expr interface {
+    expr()
+}
+
+// And matches when every operand matches.
+type And struct{ Operands []Expr }
+
+// Or matches when any operand matches.
+type Or struct{ Operands []Expr }
+
+// Not matches when its operand does not.
+type Not struct{ Operand Expr }
+
+// Compare tests one field. Op is one of = != > >= < <= ~ in; Values holds a
+// single raw value except for in.
+type Compare struct {
+    Field  string
+    Op     string
+    Values []string
+    Pos    int
+}
+
+// Truthy is a bare boolean field.
+type Truthy struct {
+    Field string
+    Pos   int
+}
+
+func (And) expr()     {}
+func (Or) expr()      {}
+func (Not) expr()     {}
+func (Compare) expr() {}
+func (Truthy) expr()  {}
+
+// SyntaxError reports a malformed filter. Pos is a 0-based byte offset.
+type SyntaxError struct {
+    Pos int
+    Msg string
+}
+
+func (e *SyntaxError) Error() string {
+    return fmt.Sprintf("filter: %s at position %d", e.Msg, e.Pos)
+}
+
+type tokenKind int
+
+const (
+    tokEOF tokenKind = iota
+    tokWord
+    tokString
+    tokOp
+    tokLParen
+    tokRParen
+    tokComma
+)
+
+type token struct {
+    kind tokenKind
+    text string
+    pos  int
+}
+
+func (t token) describe() string {
+    switch t.kind {
+    case tokEOF:
+        return "end of filter"
+    case tokString:
+        return fmt.Sprintf("string %q", t.text)
+    }
+    return fmt.Sprintf("%q", t.text)
+}
+
+func (t token) keyword(k string) bool {
+    return t.kind == tokWord && strings.EqualFold(t.text, k)
+}
+
+func lex(s string) ([]token, error) {
+    var toks []token
+    for i := 0; i < len(s); {
+        ch := s[i]
+        switch {
+        case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+            i++
+        case ch == '(':
+            toks = append(toks, token{tokLParen, "(", i})
+            i++
+        case ch == ')':
+            toks = append(toks, token{tokRParen, ")", i})
+            i++
+        case ch == ',':
+            toks = append(toks, token{tokComma, ",", i})
+            i++
+        case ch == '"' || ch == '\'':
+            start := i
+            var b strings.Builder
+            i++
+            closed := false
+            for i < len(s) {
+                if s[i] == '\\' && i+1 < len(s) {
+                    b.WriteByte(s[i+1])
+                    i += 2
+                    continue
+                }
+                if s[i] == ch {
+                    closed = true
+                    i++
+                    break
+                }
+                b.WriteByte(s[i])
+                i++
+            }
+            if !closed {
+                return nil, &SyntaxError{start, "unterminated string"}
+            }
+            toks = append(toks, token{tokString, b.String(), start})
+        case strings.ContainsRune("=!<>~", rune(ch)):
+            start := i
+            op := string(ch)
+            if i+1 < len(s) && s[i+1] == '=' && ch != '=' && ch != '~' {
+                op += "="
+            }
+            if op == "!" {
+                return nil, &SyntaxError{start, `"!" must be followed by "="`}
+            }
+            i += len(op)
+            toks = append(toks, token{tokOp, op, start})
+        case isWordByte(ch):
+            start := i
+            for i < len(s) && isWordByte(s[i]) {
+                i++
+            }
+            toks = append(toks, token{tokWord, s[start:i], start})
+        default:
+            return nil, &SyntaxError{i, fmt.Sprintf("unexpected character %q", ch)}
+        }
+    }
+    return append(toks, token{tokEOF, "", len(s)}), nil
+}
+
+// isWordByte accepts the characters of field names and unquoted values,
+// including dates (2024-01-02T10:00:00Z), decimals and UTF-8 letters.
+func isWordByte(b byte) bool {
+    return b >= 0x80 || b == '_' || b == '-' || b == '.' || b == ':' || b == '+' ||
+        (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+type parser struct {
+    toks  []token
+    i     int
+    depth int
+}
+
+// Parse parses a filter expression into its AST.
+func Parse(s string) (Expr, error) {
+    if len(s) > maxFilterLength {
+        return nil, &SyntaxError{maxFilterLength, fmt.Sprintf("filter longer than %d characters", maxFilterLength)}
+    }
+    toks, err := lex(s)
+    if err != nil {
+        return nil, err
+    }
+    p := &parser{toks: toks}
+    if p.peek().kind == tokEOF {
+        return nil, &SyntaxError{0, "empty filter"}
+    }
+    e, err := p.parseOr()
+    if err != nil {
+        return nil, err
+    }
+    if t := p.peek(); t.kind != tokEOF {
+        return nil, &SyntaxError{t.pos, "unexpected " + t.describe() + `, expected "and", "or" or end of filter`}
+    }
+    return e, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+    t := p.toks[p.i]
+    if t.kind != tokEOF {
+        p.i++
+    }
+    return t
+}
+
+func (p *parser) parseOr() (Expr, error) {
+    first, err := p.parseAnd()
+    if err != nil {
+        return nil, err
+    }
+    ops := []Expr{first}
+    for p.peek().keyword("or") {
+        p.next()
+        e, err := p.parseAnd()
+        if err != nil {
+            return nil, err
+        }
+        ops = append(ops, e)
+    }
+    if len(ops) == 1 {
+        return first, nil
+    }
+    return Or{ops}, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+    first, err := p.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    ops := []Expr{first}
+    for p.peek().keyword("and") {
+        p.next()
+        e, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        ops = append(ops, e)
+    }
+    if len(ops) == 1 {
+        return first, nil
+    }
+    return And{ops}, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+    p.depth++
+    defer func() { p.depth-- }()
+    if p.depth > maxDepth {
+        return nil, &SyntaxError{p.peek().pos, "filter nested too deeply"}
+    }
+    if p.peek().keyword("not") {
+        p.next()
+        e, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        return Not{e}, nil
+    }
+    return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+    t := p.next()
+    switch {
+    case t.kind == tokLParen:
+        e, err := p.parseOr()
+        if err != nil {
+            return nil, err
+        }
+        if r := p.next(); r.kind != tokRParen {
+            return nil, &SyntaxError{r.pos, "expected \")\", got " + r.describe()}
+        }
+        return e, nil
+    case t.kind == tokWord && !isKeyword(t.text):
+        field := t.text
+        op := p.peek()
+        if op.kind == tokOp {
+            p.next()
+            v, err := p.value()
+            if err != nil {
+                return nil, err
+            }
+            return Compare{Field: field, Op: op.text, Values: []string{v}, Pos: t.pos}, nil
+        }
+        if op.keyword("in") {
+            p.next()
+            values, err := p.list()
+            if err != nil {
+                return nil, err
+            }
+            return Compare{Field: field, Op: "in", Values: values, Pos: t.pos}, nil
+        }
+        return Truthy{Field: field, Pos: t.pos}, nil
+    }
+    return nil, &SyntaxError{t.pos, "expected a field, \"not\" or \"(\", got " + t.describe()}
+}
+
+func (p *parser) value() (string, error) {
+    t := p.next()
+    if t.kind == tokString || t.kind == tokWord {
+        return t.text, nil
+    }
+    return "", &SyntaxError{t.pos, "expected a value, got " + t.describe()}
+}
+
+func (p *parser) list() ([]string, error) {
+    if t := p.next(); t.kind != tokLParen {
+        return nil, &SyntaxError{t.pos, "expected \"(\" after in, got " + t.describe()}
+    }
+    var values []string
+    for {
+        v, err := p.value()
+        if err != nil {
+            return nil, err
+        }
+        values = append(values, v)
+        t := p.next()
+        if t.kind == tokRParen {
+            return values, nil
+        }
+        if t.kind != tokComma {
+            return nil, &SyntaxError{t.pos, "expected \",\" or \")\", got " + t.describe()}
+        }
+    }
+}
+
+func isKeyword(s string) bool {
+    switch strings.ToLower(s) {
+    case "and", "or", "not", "in":
+        return true
+    }
+    return false
+}
@@ -0,0 +1,104 @@
+package query
+
+import (
+    "errors"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func cmp(field, op string, pos int, values ...string) Compare {
+    return Compare{Field: field, Op: op, Values: values, Pos: pos}
+}
+
+func TestParse(t *testing.T) {
+    tests := []struct {
+        in   string
+        want Expr
+    }{
+        {`adopted`, Truthy{Field: "adopted", Pos: 0}},
+        {`age>=2`, cmp("age", ">=", 0, "2")},
+        {`age >= 2`, cmp("age", ">=", 0, "2")},
+        {`name != Misu`, cmp("name", "!=", 0, "Misu")},
+        {`name~mi`, cmp("name", "~", 0, "mi")},
+        {`age<3 or age>10`, Or{[]Expr{cmp("age", "<", 0, "3"), cmp("age", ">", 9, "10")}}},
+        // the request's example
+        {`age>=2 and (species=cat or species=dog) and not adopted`, And{[]Expr{
+            cmp("age", ">=", 0, "2"),
+            Or{[]Expr{cmp("species", "=", 12, "cat"), cmp("species", "=", 27, "dog")}},
+            Not{Truthy{Field: "adopted", Pos: 48}},
+        }}},
+        // and binds tighter than or
+        {`a or b and c`, Or{[]Expr{Truthy{"a", 0}, And{[]Expr{Truthy{"b", 5}, Truthy{"c", 11}}}}}},
+        {`a and b or c`, Or{[]Expr{And{[]Expr{Truthy{"a", 0}, Truthy{"b", 6}}}, Truthy{"c", 11}}}},
+        // not binds tighter than and
+        {`not a and b`, And{[]Expr{Not{Truthy{"a", 4}}, Truthy{"b", 10}}}},
+        {`not not a`, Not{Not{Truthy{"a", 8}}}},
+        {`not (a or b)`, Not{Or{[]Expr{Truthy{"a", 5}, Truthy{"b", 10}}}}},
+        {`(a or b) and c`, And{[]Expr{Or{[]Expr{Truthy{"a", 1}, Truthy{"b", 6}}}, Truthy{"c", 13}}}},
+        {`((a))`, Truthy{"a", 2}},
+        // keywords are case-insensitive
+        {`a AND NOT b Or c`, Or{[]Expr{And{[]Expr{Truthy{"a", 0}, Not{Truthy{"b", 10}}}}, Truthy{"c", 15}}}},
+        // quoted strings keep spaces, operators and keywords as text
+        {`name="Black Jack"`, cmp("name", "=", 0, "Black Jack")},
+        {`name='a and (b)'`, cmp("name", "=", 0, "a and (b)")},
+        {`name="say \"hi\""`, cmp("name", "=", 0, `say "hi"`)},
+        {`name="or"`, cmp("name", "=", 0, "or")},
+        // numbers, booleans and dates are words; the compiler types them
+        {`age=-1.5`, cmp("age", "=", 0, "-1.5")},
+        {`adopted=false`, cmp("adopted", "=", 0, "false")},
+        {`createdAt>=2024-01-02T10:00:00Z`, cmp("createdAt", ">=", 0, "2024-01-02T10:00:00Z")},
+        {`name=Pörrö`, cmp("name", "=", 0, "Pörrö")},
+        {`species in (cat, "big dog",3)`, cmp("species", "in", 0, "cat", "big dog", "3")},
+    }
+    for _, tt := range tests {
+        got, err := Parse(tt.in)
+        if err != nil {
+            t.Errorf("Parse(%q): %v", tt.in, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("Parse(%q)\n got %#v\nwant %#v", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestParseErrors(t *testing.T) {
+    tests := []struct {
+        in  string
+        pos int
+        msg string
+    }{
+        {``, 0, "empty filter"},
+        {`   `, 0, "empty filter"},
+        {`age >=`, 6, "expected a value"},
+        {`age = )`, 6, "expected a value"},
+        {`(age=1`, 6, `expected ")"`},
+        {`age=1)`, 5, `unexpected ")"`},
+        {`age=1 adopted`, 6, `unexpected "adopted"`},
+        {`age=1 and`, 9, "expected a field"},
+        {`and age=1`, 0, "expected a field"},
+        {`not`, 3, "expected a field"},
+        {`age ! 1`, 4, `"!" must be followed by "="`},
+        {`name="open`, 5, "unterminated string"},
+        {`age=1 & adopted`, 6, "unexpected character"},
+        {`name=$where`, 5, "unexpected character"},
+        {`species in cat`, 11, `expected "(" after in`},
+        {`species in (cat dog)`, 16, `expected "," or ")"`},
+        {`species in ()`, 12, "expected a value"},
+        {strings.Repeat("(", 40) + "a" + strings.Repeat(")", 40), 32, "nested too deeply"},
+        {strings.Repeat("not ", 40) + "a", 128, "nested too deeply"},
+        {"name=" + strings.Repeat("x", maxFilterLength), maxFilterLength, "longer than"},
+    }
+    for _, tt := range tests {
+        _, err := Parse(tt.in)
+        var se *SyntaxError
+        if !errors.As(err, &se) {
+            t.Errorf("Parse(%.40q) = %v, want a SyntaxError", tt.in, err)
+            continue
+        }
+        if se.Pos != tt.pos || !strings.Contains(se.Msg, tt.msg) {
+            t.Errorf("Parse(%.40q) = %q at %d, want %q at %d", tt.in, se.Msg, se.Pos, tt.msg, tt.pos)
+        }
+    }
+}
@@ -4,47 +4,103 @@ import (
     "github.com/gin-gonic/gin"
     "go.mongodb.org/mongo-driver/mongo"
 
+    "go-api/pkg/config"
     "go-api/pkg/controllers"
+    "go-api/pkg/middleware"
 )
 
-func RegisterAnimalRoutes(rg *gin.RouterGroup, client *mongo.Client, dbName string) {
+func RegisterAnimalRoutes(rg *gin.RouterGroup, client *mongo.Client, cfg config.Config) {
+    dbName := cfg.DatabaseName
+    rg.Use(middleware.LenientQuery(cfg.LenientQuery))
     ctrl := controllers.NewAnimalController(client, dbName)
+    ctrl.LegacyPut = cfg.LegacyPut
+    audit := controllers.NewAuditController(client, dbName)
 
     g := rg.Group("/animals")
     {
         g.POST("", ctrl.CreateAnimal)
         g.GET("", ctrl.ListAnimals)
+        g.GET("/export", ctrl.ExportAnimals)
+        g.GET("/suggest", ctrl.SuggestAnimals)
+        g.POST("/batch-get", ctrl.BatchGetAnimals)
+        g.POST("/bulk", ctrl.BulkCreateAnimals)
+        g.PATCH("/bulk", ctrl.BulkPatchAnimals)
+        g.DELETE("/bulk", ctrl.BulkDeleteAnimals)
         g.GET("/:id", ctrl.GetAnimal)
         g.PUT("/:id", ctrl.UpdateAnimal)
+        g.PATCH("/:id", ctrl.PatchAnimal)
         g.DELETE("/:id", ctrl.DeleteAnimal)
+        g.GET("/:id/history", audit.History("animals"))
     }
 
     // Categories
     cat := controllers.NewCategoryController(client, dbName)
+    cat.LegacyPut = cfg.LegacyPut
     cg := rg.Group("/categories")
     {
         cg.POST("", cat.CreateCategory)
         cg.GET("", cat.ListCategories)
+        cg.GET("/export", cat.ExportCategories)
+        cg.POST("/batch-get", cat.BatchGetCategories)
         cg.GET("/:id", cat.GetCategory)
         cg.PUT("/:id", cat.UpdateCategory)
+        cg.PATCH("/:id", cat.PatchCategory)
         cg.DELETE("/:id", cat.DeleteCategory)
     }
 
     // Species
     sp := controllers.NewSpeciesController(client, dbName)
+    sp.LegacyPut = cfg.LegacyPut
     sg := rg.Group("/species")
     {
         sg.POST("", sp.CreateSpecies)
         sg.GET("", sp.ListSpecies)
+        sg.GET("/export", sp.ExportSpecies)
+        sg.GET("/suggest", sp.SuggestSpecies)
+        sg.POST("/batch-get", sp.BatchGetSpecies)
         sg.GET("/:id", sp.GetSpecies)
         sg.PUT("/:id", sp.UpdateSpecies)
+        sg.PATCH("/:id", sp.PatchSpecies)
         sg.DELETE("/:id", sp.DeleteSpecies)
     }
 
+    // Search
+    search := controllers.NewSearchController(client, dbName)
+    rg.GET("/search", search.Search)
+
+    // Statistics
+    stats := controllers.NewStatsController(client, dbName)
+    stg := rg.Group("/stats")
+    {
+        stg.GET("/animals", stats.AnimalStats)
+        stg.GET("/species", stats.SpeciesStats)
+    }
+
+    // Reports
+    reports := controllers.NewReportController(client, dbName)
+    rpg := rg.Group("/reports")
+    {
+        rpg.GET("/timeseries", reports.Timeseries)
+    }
+
+    // CSV import
+    imp := controllers.NewImportController(client, dbName)
+    ig := rg.Group("/import")
+    {
+        ig.POST("/animals", imp.ImportAnimals)
+        ig.POST("/species", imp.ImportSpecies)
+        ig.POST("/categories", imp.ImportCategories)
+        ig.POST("/dataset", middleware.AdminOnly(cfg.AdminToken), imp.ImportDataset)
+    }
+
     // Maintenance
     mt := controllers.NewMaintenanceController(client, dbName)
     mg := rg.Group("/maintenance")
     {
         mg.POST("/backfill-timestamps", mt.BackfillTimestamps)
+        mg.POST("/backfill-sort-keys", mt.BackfillSortKeys)
     }
+
+    // Audit (admin)
+    rg.GET("/audit", middleware.AdminOnly(cfg.AdminToken), audit.ListAudit)
 }
@@ -0,0 +1,279 @@
+// Package seed generates a coherent set of categories, species and animals
+// for local development. Dates are relative to the current day, so the same
+// seed and size produce the same data when run on the same day.
+package seed
+
+import (
+    "context"
+    "encoding/binary"
+    "fmt"
+    "math/rand"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+
+    "go-api/pkg/db"
+    "go-api/pkg/models"
+    "go-api/pkg/utils"
+)
+
+// Region is the bounding box animal locations are spread over.
+type Region struct {
+    MinLng, MinLat, MaxLng, MaxLat float64
+}
+
+// SouthernFinland roughly covers Helsinki, Turku, Tampere and Lahti.
+var SouthernFinland = Region{MinLng: 21.9, MinLat: 60.0, MaxLng: 26.5, MaxLat: 61.7}
+
+// Options controls what Generate produces.
+type Options struct {
+    Animals int    // number of animals
+    Seed    int64  // random seed; the same seed gives the same data
+    Region  Region // where animals are located
+    // AdoptedRatio is the share of adopted animals, between 0 and 1.
+    AdoptedRatio float64
+    // Now anchors birthdates and timestamps; zero means the start of today.
+    Now time.Time
+}
+
+// Fixtures is a generated data set. Species reference categories and animals
+// reference species by id, like documents created through the API.
+type Fixtures struct {
+    Categories []models.Category
+    Species    []models.Species
+    Animals    []models.Animal
+}
+
+type speciesSpec struct {
+    name   string
+    maxAge int
+    names  []string
+    weight int // relative frequency among animals
+}
+
+var catalog = []struct {
+    category string
+    species  []speciesSpec
+}{
+    {"Mammals", []speciesSpec{
+        {"Dog", 16, []string{"Musti", "Rekku", "Halli", "Nappi", "Luna", "Max", "Bella", "Rocky", "Vilma", "Onni"}, 30},
+        {"Cat", 18, []string{"Mirri", "Misu", "Kisu", "Nöpö", "Viiru", "Oskari", "Lilli", "Tiikeri", "Pörrö", "Sulo"}, 30},
+        {"Rabbit", 10, []string{"Pupu", "Hoppu", "Nuppu", "Lumi", "Kaneli", "Pipsa"}, 8},
+        {"Guinea pig", 7, []string{"Possu", "Nasu", "Pallero", "Kiki", "Tupsu"}, 6},
+        {"Hamster", 3, []string{"Hamppu", "Pähkinä", "Siru", "Mörri"}, 4},
+        {"Ferret", 9, []string{"Frettu", "Vipeltäjä", "Sukkela", "Luikku"}, 2},
+    }},
+    {"Birds", []speciesSpec{
+        {"Budgerigar", 10, []string{"Sirkku", "Tipu", "Kultsi", "Pilvi", "Sini"}, 5},
+        {"Cockatiel", 20, []string{"Kaija", "Töyhtö", "Sunny", "Pippuri"}, 3},
+        {"Chicken", 8, []string{"Kotkot", "Helmi", "Muna", "Rouva Kana"}, 3},
+    }},
+    {"Reptiles", []speciesSpec{
+        {"Bearded dragon", 12, []string{"Drago", "Liskonen", "Hiekka", "Rex"}, 2},
+        {"Corn snake", 20, []string{"Sihis", "Köysi", "Oranssi", "Kiemura"}, 1},
+        {"Tortoise", 80, []string{"Konna", "Vanhus", "Kivi", "Hitaus"}, 1},
+    }},
+    {"Fish", []speciesSpec{
+        {"Goldfish", 15, []string{"Kulta", "Evä", "Kupla", "Nemo"}, 3},
+        {"Betta", 4, []string{"Siima", "Loisto", "Tähti"}, 2},
+    }},
+}
+
+var owners = []string{
+    "Aino Virtanen", "Eero Korhonen", "Helmi Mäkinen", "Juho Nieminen", "Kaisa Mäkelä",
+    "Lauri Hämäläinen", "Minna Laine", "Niko Heikkinen", "Oona Koskinen", "Pekka Järvinen",
+    "Riikka Lehtonen", "Sami Lehtinen", "Tiina Saarinen", "Ville Salminen", "Aleksi Heinonen",
+    "Emilia Niemi", "Hanna Heikkilä", "Joonas Kinnunen", "Laura Salonen", "Mikko Turunen",
+}
+
+// Generate builds fixtures from opts. It does not touch the database.
+func Generate(opts Options) Fixtures {
+    now := opts.Now
+    if now.IsZero() {
+        now = time.Now().UTC().Truncate(24 * time.Hour)
+    }
+    now = now.UTC()
+    if opts.Region == (Region{}) {
+        opts.Region = SouthernFinland
+    }
+    rng := rand.New(rand.NewSource(opts.Seed))
+    // Fixtures look like they were entered over the past two years.
+    start := now.AddDate(-2, 0, 0)
+    var fx Fixtures
+
+    type pick struct {
+        species *models.Species
+        spec    speciesSpec
+    }
+    var pool []pick
+    total := 0
+    for _, group := range catalog {
+        created := randomTime(rng, start, start.AddDate(0, 1, 0))
+        cat := models.Category{
+            ID:        objectID(rng, created),
+            Name:      group.category,
+            CreatedAt: created,
+            UpdatedAt: created,
+            Version:   1,
+        }
+        fx.Categories = append(fx.Categories, cat)
+        for _, spec := range group.species {
+            created := randomTime(rng, cat.CreatedAt, cat.CreatedAt.AddDate(0, 1, 0))
+            fx.Species = append(fx.Species, models.Species{
+                ID:        objectID(rng, created),
+                Name:      spec.name,
+                Category:  cat.ID.Hex(),
+                CreatedAt: created,
+                UpdatedAt: created,
+                Version:   1,
+            })
+            total += spec.weight
+        }
+    }
+    i := 0
+    for _, group := range catalog {
+        for _, spec := range group.species {
+            pool = append(pool, pick{species: &fx.Species[i], spec: spec})
+            i++
+        }
+    }
+
+    animalsFrom := start.AddDate(0, 2, 0)
+    for n := 0; n < opts.Animals; n++ {
+        r := rng.Intn(total)
+        var p pick
+        for _, candidate := range pool {
+            if r < candidate.spec.weight {
+                p = candidate
+                break
+            }
+            r -= candidate.spec.weight
+        }
+        created := randomTime(rng, animalsFrom, now)
+        // Younger animals are more common; birthdates never follow intake.
+        maxDays := p.spec.maxAge * 365
+        days := int(float64(maxDays) * rng.Float64() * rng.Float64())
+        birthdate := created.AddDate(0, 0, -days)
+        a := models.Animal{
+            ID:        objectID(rng, created),
+            Name:      p.spec.names[rng.Intn(len(p.spec.names))],
+            Species:   p.species.ID.Hex(),
+            Age:       utils.AgeAt(birthdate, now),
+            CreatedAt: created,
+            UpdatedAt: created,
+            Version:   1,
+            Location:  randomPoint(rng, opts.Region),
+        }
+        if a.Age < 0 {
+            a.Age = 0
+        }
+        if rng.Float64() < opts.AdoptedRatio {
+            a.Adopted = true
+            a.Owner = owners[rng.Intn(len(owners))]
+            a.UpdatedAt = randomTime(rng, created, now)
+            adoptedAt := a.UpdatedAt
+            a.AdoptedAt = &adoptedAt
+            a.Version = 2
+        }
+        if rng.Intn(3) > 0 {
+            a.Image = fmt.Sprintf("https://picsum.photos/seed/%s/640/480", a.ID.Hex())
+        }
+        fx.Animals = append(fx.Animals, a)
+    }
+    return fx
+}
+
+// Insert writes fixtures into database. With reset it first empties the
+// animals, species and categories collections.
+func Insert(ctx context.Context, database *mongo.Database, fx Fixtures, reset bool) error {
+    if reset {
+        for _, name := range []string{"animals", "species", "categories"} {
+            if _, err := database.Collection(name).DeleteMany(ctx, bson.M{}); err != nil {
+                return fmt.Errorf("clearing %s: %w", name, err)
+            }
+        }
+    }
+    if err := insertAll(ctx, database.Collection("categories"), fx.Categories); err != nil {
+        return err
+    }
+    if err := insertAll(ctx, database.Collection("species"), fx.Species); err != nil {
+        return err
+    }
+    if err := insertAll(ctx, database.Collection("animals"), fx.Animals); err != nil {
+        return err
+    }
+    // Location queries need a geo index; creating it again is a no-op.
+    _, err := database.Collection("animals").Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys: bson.D{{Key: "location", Value: "2dsphere"}},
+    })
+    if err != nil {
+        return err
+    }
+    return db.BackfillSortKeys(ctx, database)
+}
+
+func insertAll[T any](ctx context.Context, coll *mongo.Collection, items []T) error {
+    if len(items) == 0 {
+        return nil
+    }
+    docs := make([]interface{}, len(items))
+    for i := range items {
+        docs[i] = items[i]
+    }
+    if _, err := coll.InsertMany(ctx, docs); err != nil {
+        return fmt.Errorf("inserting %s: %w", coll.Name(), err)
+    }
+    return nil
+}
+
+// objectID derives an id from the rng so ids are reproducible too; the
+// timestamp part matches created like a server-generated id would.
+func objectID(rng *rand.Rand, created time.Time) primitive.ObjectID {
+    var id primitive.ObjectID
+    binary.BigEndian.PutUint32(id[0:4], uint32(created.Unix()))
+    binary.BigEndian.PutUint64(id[4:12], rng.Uint64())
+    return id
+}
+
+func randomTime(rng *rand.Rand, from, to time.Time) time.Time {
+    if !to.After(from) {
+        return from
+    }
+    d := rng.Int63n(int64(to.Sub(from)))
+    return from.Add(time.Duration(d)).Truncate(time.Second)
+}
+
+// randomPoint spreads most animals around a few towns in the region and the
+// rest uniformly, which gives geo queries something to cluster on.
+func randomPoint(rng *rand.Rand, r Region) *models.GeoPoint {
+    var lng, lat float64
+    if rng.Intn(4) > 0 {
+        // Pick a hotspot inside the region and scatter within ~20 km of it.
+        hx := float64(rng.Intn(4)+1) / 5
+        hy := float64(rng.Intn(3)+1) / 4
+        lng = r.MinLng + hx*(r.MaxLng-r.MinLng) + rng.NormFloat64()*0.15
+        lat = r.MinLat + hy*(r.MaxLat-r.MinLat) + rng.NormFloat64()*0.08
+    } else {
+        lng = r.MinLng + rng.Float64()*(r.MaxLng-r.MinLng)
+        lat = r.MinLat + rng.Float64()*(r.MaxLat-r.MinLat)
+    }
+    lng = clamp(lng, r.MinLng, r.MaxLng)
+    lat = clamp(lat, r.MinLat, r.MaxLat)
+    return &models.GeoPoint{Type: "Point", Coordinates: []float64{round6(lng), round6(lat)}}
+}
+
+func clamp(v, lo, hi float64) float64 {
+    if v < lo {
+        return lo
+    }
+    if v > hi {
+        return hi
+    }
+    return v
+}
+
+func round6(v float64) float64 {
+    return float64(int64(v*1e6+0.5)) / 1e6
+}
@@ -0,0 +1,40 @@
+package seed
+
+import (
+    "reflect"
+    "testing"
+    "time"
+)
+
+func TestGenerateIsDeterministic(t *testing.T) {
+    opts := Options{Animals: 200, Seed: 42, AdoptedRatio: 0.3, Now: time.Date(2010, 6, 1, 0, 0, 0, 0, time.UTC)}
+    a, b := Generate(opts), Generate(opts)
+    if !reflect.DeepEqual(a, b) {
+        t.Fatal("the same Seed and Now gave different fixtures")
+    }
+    if other := Generate(Options{Animals: 200, Seed: 43, AdoptedRatio: 0.3, Now: opts.Now}); reflect.DeepEqual(a.Animals, other.Animals) {
+        t.Error("different seeds gave the same animals")
+    }
+
+    // Ages are relative to Now, not the clock the test runs on.
+    maxAge := map[string]int{}
+    for _, group := range catalog {
+        for _, spec := range group.species {
+            maxAge[spec.name] = spec.maxAge
+        }
+    }
+    species := map[string]string{}
+    for _, s := range a.Species {
+        species[s.ID.Hex()] = s.Name
+    }
+    for _, an := range a.Animals {
+        name := species[an.Species]
+        // birthdates are at most maxAge years before intake, which is within two years of Now
+        if an.Age < 0 || an.Age > maxAge[name]+2 {
+            t.Errorf("%s %s: age %d at %s", name, an.Name, an.Age, opts.Now.Format("2006-01-02"))
+        }
+        if an.CreatedAt.After(opts.Now) {
+            t.Errorf("%s created at %s, after Now", an.Name, an.CreatedAt)
+        }
+    }
+}
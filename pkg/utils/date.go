@@ -27,10 +27,16 @@ func AgeFromBirthdate(s string) int {
 
 // AgeFromTime computes age in full years from a time.Time birthdate.
 func AgeFromTime(t time.Time) int {
+    return AgeAt(t, time.Now().UTC())
+}
+
+// AgeAt computes age in full years on the date of now from a time.Time
+// birthdate. Returns -1 for a zero birthdate or one after now.
+func AgeAt(t, now time.Time) int {
     if t.IsZero() {
         return -1
     }
-    now := time.Now().UTC()
+    now = now.UTC()
     years := now.Year() - t.Year()
     bday := time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
     if now.Before(bday) {
@@ -0,0 +1,25 @@
+package utils
+
+import (
+    "testing"
+    "time"
+)
+
+func TestAgeAt(t *testing.T) {
+    now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+    tests := []struct {
+        birthdate time.Time
+        want      int
+    }{
+        {time.Time{}, -1},
+        {time.Date(2020, 6, 15, 0, 0, 0, 0, time.UTC), 4},
+        {time.Date(2020, 6, 16, 0, 0, 0, 0, time.UTC), 3},
+        {time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 0},
+        {time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), -1},
+    }
+    for _, tt := range tests {
+        if got := AgeAt(tt.birthdate, now); got != tt.want {
+            t.Errorf("AgeAt(%s) = %d, want %d", tt.birthdate.Format("2006-01-02"), got, tt.want)
+        }
+    }
+}
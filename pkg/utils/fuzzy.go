@@ -0,0 +1,72 @@
+package utils
+
+import (
+    "strings"
+    "unicode"
+)
+
+// EditDistance returns the Damerau-Levenshtein (optimal string alignment)
+// distance between a and b, counting runes: insertions, deletions,
+// substitutions and transpositions of adjacent characters each cost 1.
+func EditDistance(a, b string) int {
+    ra, rb := []rune(a), []rune(b)
+    if len(ra) == 0 {
+        return len(rb)
+    }
+    if len(rb) == 0 {
+        return len(ra)
+    }
+    // Three rolling rows are enough for the transposition lookback.
+    prev2 := make([]int, len(rb)+1)
+    prev := make([]int, len(rb)+1)
+    cur := make([]int, len(rb)+1)
+    for j := range prev {
+        prev[j] = j
+    }
+    for i := 1; i <= len(ra); i++ {
+        cur[0] = i
+        for j := 1; j <= len(rb); j++ {
+            cost := 1
+            if ra[i-1] == rb[j-1] {
+                cost = 0
+            }
+            cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+            if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+                cur[j] = min(cur[j], prev2[j-2]+1)
+            }
+        }
+        prev2, prev, cur = prev, cur, prev2
+    }
+    return prev[len(rb)]
+}
+
+// TrigramSimilarity returns the Jaccard similarity (0..1) of the character
+// trigram sets of a and b, case-insensitively. Words are padded with spaces
+// as in PostgreSQL's pg_trgm, so short words and word starts weigh in.
+func TrigramSimilarity(a, b string) float64 {
+    ta, tb := trigrams(a), trigrams(b)
+    if len(ta) == 0 || len(tb) == 0 {
+        return 0
+    }
+    shared := 0
+    for t := range ta {
+        if tb[t] {
+            shared++
+        }
+    }
+    return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(s string) map[string]bool {
+    out := map[string]bool{}
+    words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+    for _, w := range words {
+        r := []rune("  " + w + " ")
+        for i := 0; i+3 <= len(r); i++ {
+            out[string(r[i:i+3])] = true
+        }
+    }
+    return out
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+    "math"
+    "testing"
+)
+
+func TestEditDistance(t *testing.T) {
+    tests := []struct {
+        a, b string
+        want int
+    }{
+        {"", "", 0},
+        {"", "cat", 3},
+        {"cat", "", 3},
+        {"cat", "cat", 0},
+        {"cat", "cut", 1},
+        {"cat", "cats", 1},
+        {"cats", "cat", 1},
+        {"kitten", "sitting", 3},
+        {"gustave", "gustav", 1},
+        {"tiikri", "tiikeri", 1},
+        // adjacent transpositions cost 1
+        {"ab", "ba", 1},
+        {"tigre", "tiger", 1},
+        // optimal string alignment: no edits inside a transposed pair
+        {"ca", "abc", 3},
+        // runes, not bytes
+        {"käärme", "kaarme", 2},
+        {"öö", "ö", 1},
+        // case-sensitive; callers lower-case first
+        {"Cat", "cat", 1},
+    }
+    for _, tt := range tests {
+        if got := EditDistance(tt.a, tt.b); got != tt.want {
+            t.Errorf("EditDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+        }
+        if got := EditDistance(tt.b, tt.a); got != tt.want {
+            t.Errorf("EditDistance(%q, %q) = %d, want %d (symmetry)", tt.b, tt.a, got, tt.want)
+        }
+    }
+}
+
+func TestTrigramSimilarity(t *testing.T) {
+    tests := []struct {
+        a, b string
+        want float64
+    }{
+        {"", "", 0},
+        {"cat", "", 0},
+        {"cat", "cat", 1},
+        {"Cat", "cAT", 1},
+        // "  c"," ca","cat","at " and "  d"," do","dog","og " share nothing
+        {"cat", "dog", 0},
+        // cat: "  c"," ca","cat","at "; cats: "  c"," ca","cat","ats","ts "
+        {"cat", "cats", 3.0 / 6},
+        // words are padded separately, so order does not matter
+        {"black jack", "jack black", 1},
+        {"black-jack", "black jack", 1},
+    }
+    for _, tt := range tests {
+        got := TrigramSimilarity(tt.a, tt.b)
+        if math.Abs(got-tt.want) > 1e-9 {
+            t.Errorf("TrigramSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+        }
+    }
+    if s := TrigramSimilarity("gustave", "gustav"); s < 0.5 || s >= 1 {
+        t.Errorf("TrigramSimilarity(gustave, gustav) = %v, want in [0.5, 1)", s)
+    }
+}
@@ -17,3 +17,11 @@ func NotFound(c *gin.Context) {
 func ServerError(c *gin.Context, err error) {
     c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 }
+
+func PreconditionFailed(c *gin.Context) {
+    c.JSON(http.StatusPreconditionFailed, gin.H{"error": "precondition failed: document has been modified"})
+}
+
+func TooLarge(c *gin.Context, err error) {
+    c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+}
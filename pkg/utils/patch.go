@@ -0,0 +1,275 @@
+package utils
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "reflect"
+    "strconv"
+    "strings"
+)
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to doc. A null value in the
+// patch removes the member; objects are merged recursively; anything else
+// replaces the target value.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+    var target, p interface{}
+    if err := json.Unmarshal(doc, &target); err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(patch, &p); err != nil {
+        return nil, fmt.Errorf("invalid merge patch: %w", err)
+    }
+    return json.Marshal(mergeValue(target, p))
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+    pm, ok := patch.(map[string]interface{})
+    if !ok {
+        return patch
+    }
+    tm, ok := target.(map[string]interface{})
+    if !ok {
+        tm = map[string]interface{}{}
+    }
+    for k, v := range pm {
+        if v == nil {
+            delete(tm, k)
+            continue
+        }
+        tm[k] = mergeValue(tm[k], v)
+    }
+    return tm
+}
+
+// PatchOp is one RFC 6902 JSON Patch operation.
+type PatchOp struct {
+    Op    string          `json:"op"`
+    Path  string          `json:"path"`
+    From  string          `json:"from,omitempty"`
+    Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch applies an RFC 6902 JSON Patch to doc. Operations are applied in
+// order and the whole patch fails if any operation fails.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+    var target interface{}
+    if err := json.Unmarshal(doc, &target); err != nil {
+        return nil, err
+    }
+    var ops []PatchOp
+    if err := json.Unmarshal(patch, &ops); err != nil {
+        return nil, fmt.Errorf("invalid json patch: %w", err)
+    }
+    var err error
+    for i, op := range ops {
+        if target, err = applyOp(target, op); err != nil {
+            return nil, fmt.Errorf("json patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+        }
+    }
+    return json.Marshal(target)
+}
+
+func applyOp(doc interface{}, op PatchOp) (interface{}, error) {
+    var value interface{}
+    switch op.Op {
+    case "add", "replace", "test":
+        if len(op.Value) == 0 {
+            return nil, errors.New("value is required")
+        }
+        if err := json.Unmarshal(op.Value, &value); err != nil {
+            return nil, err
+        }
+    }
+    switch op.Op {
+    case "add":
+        return pointerAdd(doc, op.Path, value)
+    case "remove":
+        doc, _, err := pointerRemove(doc, op.Path)
+        return doc, err
+    case "replace":
+        doc, _, err := pointerRemove(doc, op.Path)
+        if err != nil {
+            return nil, err
+        }
+        return pointerAdd(doc, op.Path, value)
+    case "move":
+        if strings.HasPrefix(op.Path, op.From+"/") {
+            return nil, errors.New("cannot move a value into itself")
+        }
+        doc, v, err := pointerRemove(doc, op.From)
+        if err != nil {
+            return nil, err
+        }
+        return pointerAdd(doc, op.Path, v)
+    case "copy":
+        v, err := pointerGet(doc, op.From)
+        if err != nil {
+            return nil, err
+        }
+        return pointerAdd(doc, op.Path, deepCopy(v))
+    case "test":
+        v, err := pointerGet(doc, op.Path)
+        if err != nil {
+            return nil, err
+        }
+        if !reflect.DeepEqual(v, value) {
+            return nil, errors.New("test failed")
+        }
+        return doc, nil
+    }
+    return nil, fmt.Errorf("unsupported op %q", op.Op)
+}
+
+// splitPointer parses an RFC 6901 JSON Pointer into unescaped reference tokens.
+func splitPointer(ptr string) ([]string, error) {
+    if ptr == "" {
+        return nil, nil
+    }
+    if !strings.HasPrefix(ptr, "/") {
+        return nil, fmt.Errorf("invalid pointer %q", ptr)
+    }
+    parts := strings.Split(ptr[1:], "/")
+    for i, p := range parts {
+        parts[i] = strings.ReplaceAll(strings.ReplaceAll(p, "~1", "/"), "~0", "~")
+    }
+    return parts, nil
+}
+
+func arrayIndex(tok string, n int, allowEnd bool) (int, error) {
+    if allowEnd && tok == "-" {
+        return n, nil
+    }
+    i, err := strconv.Atoi(tok)
+    if err != nil || i < 0 || i > n || (i == n && !allowEnd) {
+        return 0, fmt.Errorf("invalid array index %q", tok)
+    }
+    return i, nil
+}
+
+func pointerGet(doc interface{}, ptr string) (interface{}, error) {
+    toks, err := splitPointer(ptr)
+    if err != nil {
+        return nil, err
+    }
+    cur := doc
+    for _, t := range toks {
+        switch node := cur.(type) {
+        case map[string]interface{}:
+            v, ok := node[t]
+            if !ok {
+                return nil, fmt.Errorf("path %q not found", ptr)
+            }
+            cur = v
+        case []interface{}:
+            i, err := arrayIndex(t, len(node), false)
+            if err != nil {
+                return nil, err
+            }
+            cur = node[i]
+        default:
+            return nil, fmt.Errorf("path %q not found", ptr)
+        }
+    }
+    return cur, nil
+}
+
+// pointerAdd sets value at ptr and returns the (possibly new) root.
+func pointerAdd(doc interface{}, ptr string, value interface{}) (interface{}, error) {
+    toks, err := splitPointer(ptr)
+    if err != nil {
+        return nil, err
+    }
+    if len(toks) == 0 {
+        return value, nil
+    }
+    parentPtr := ptr[:strings.LastIndex(ptr, "/")]
+    parent, err := pointerGet(doc, parentPtr)
+    if err != nil {
+        return nil, err
+    }
+    last := toks[len(toks)-1]
+    switch node := parent.(type) {
+    case map[string]interface{}:
+        node[last] = value
+        return doc, nil
+    case []interface{}:
+        i, err := arrayIndex(last, len(node), true)
+        if err != nil {
+            return nil, err
+        }
+        node = append(node, nil)
+        copy(node[i+1:], node[i:])
+        node[i] = value
+        return pointerReplaceArray(doc, parentPtr, node)
+    }
+    return nil, fmt.Errorf("cannot add at %q", ptr)
+}
+
+// pointerRemove deletes the value at ptr and returns the new root and the removed value.
+func pointerRemove(doc interface{}, ptr string) (interface{}, interface{}, error) {
+    toks, err := splitPointer(ptr)
+    if err != nil {
+        return nil, nil, err
+    }
+    if len(toks) == 0 {
+        return nil, doc, nil
+    }
+    parentPtr := ptr[:strings.LastIndex(ptr, "/")]
+    parent, err := pointerGet(doc, parentPtr)
+    if err != nil {
+        return nil, nil, err
+    }
+    last := toks[len(toks)-1]
+    switch node := parent.(type) {
+    case map[string]interface{}:
+        v, ok := node[last]
+        if !ok {
+            return nil, nil, fmt.Errorf("path %q not found", ptr)
+        }
+        delete(node, last)
+        return doc, v, nil
+    case []interface{}:
+        i, err := arrayIndex(last, len(node), false)
+        if err != nil {
+            return nil, nil, err
+        }
+        v := node[i]
+        node = append(node[:i:i], node[i+1:]...)
+        doc, err = pointerReplaceArray(doc, parentPtr, node)
+        return doc, v, err
+    }
+    return nil, nil, fmt.Errorf("path %q not found", ptr)
+}
+
+// pointerReplaceArray stores a resized array back into its parent, since
+// appending may have reallocated it.
+func pointerReplaceArray(doc interface{}, ptr string, arr []interface{}) (interface{}, error) {
+    toks, _ := splitPointer(ptr)
+    if len(toks) == 0 {
+        return arr, nil
+    }
+    parent, err := pointerGet(doc, ptr[:strings.LastIndex(ptr, "/")])
+    if err != nil {
+        return nil, err
+    }
+    last := toks[len(toks)-1]
+    switch node := parent.(type) {
+    case map[string]interface{}:
+        node[last] = arr
+    case []interface{}:
+        i, err := arrayIndex(last, len(node), false)
+        if err != nil {
+            return nil, err
+        }
+        node[i] = arr
+    }
+    return doc, nil
+}
+
+func deepCopy(v interface{}) interface{} {
+    b, _ := json.Marshal(v)
+    var out interface{}
+    _ = json.Unmarshal(b, &out)
+    return out
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+)
+
+func jsonEqual(t *testing.T, got []byte, want string) bool {
+    t.Helper()
+    var g, w interface{}
+    if err := json.Unmarshal(got, &g); err != nil {
+        t.Fatalf("result %s: %v", got, err)
+    }
+    if err := json.Unmarshal([]byte(want), &w); err != nil {
+        t.Fatalf("want %s: %v", want, err)
+    }
+    return reflect.DeepEqual(g, w)
+}
+
+// The examples of RFC 7396 Appendix A.
+func TestMergePatch(t *testing.T) {
+    tests := []struct {
+        doc, patch, want string
+    }{
+        {`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+        {`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+        {`{"a":"b"}`, `{"a":null}`, `{}`},
+        {`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+        {`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+        {`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+        {`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+        {`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+        {`["a","b"]`, `["c","d"]`, `["c","d"]`},
+        {`{"a":"b"}`, `["c"]`, `["c"]`},
+        {`{"a":"foo"}`, `null`, `null`},
+        {`{"a":"foo"}`, `"bar"`, `"bar"`},
+        {`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+        {`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+        {`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+        // null deletes a key, also when it is missing
+        {`{"a":1,"b":{"c":2}}`, `{"b":{"c":null},"x":null}`, `{"a":1,"b":{}}`},
+    }
+    for _, tt := range tests {
+        got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+        if err != nil {
+            t.Errorf("MergePatch(%s, %s): %v", tt.doc, tt.patch, err)
+            continue
+        }
+        if !jsonEqual(t, got, tt.want) {
+            t.Errorf("MergePatch(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+        }
+    }
+}
+
+// The examples of RFC 6902 Appendix A, and edge cases of pointers and ops.
+// A want of "" means the patch must fail.
+func TestJSONPatch(t *testing.T) {
+    tests := []struct {
+        name, doc, patch, want string
+    }{
+        {"A.1 add object member", `{"foo":"bar"}`,
+            `[{"op":"add","path":"/baz","value":"qux"}]`,
+            `{"baz":"qux","foo":"bar"}`},
+        {"A.2 add array element", `{"foo":["bar","baz"]}`,
+            `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+            `{"foo":["bar","qux","baz"]}`},
+        {"A.3 remove object member", `{"baz":"qux","foo":"bar"}`,
+            `[{"op":"remove","path":"/baz"}]`,
+            `{"foo":"bar"}`},
+        {"A.4 remove array element", `{"foo":["bar","qux","baz"]}`,
+            `[{"op":"remove","path":"/foo/1"}]`,
+            `{"foo":["bar","baz"]}`},
+        {"A.5 replace", `{"baz":"qux","foo":"bar"}`,
+            `[{"op":"replace","path":"/baz","value":"boo"}]`,
+            `{"baz":"boo","foo":"bar"}`},
+        {"A.6 move value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+            `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+            `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+        {"A.7 move array element", `{"foo":["all","grass","cows","eat"]}`,
+            `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+            `{"foo":["all","cows","eat","grass"]}`},
+        {"A.8 test success", `{"baz":"qux","foo":["a",2,"c"]}`,
+            `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+            `{"baz":"qux","foo":["a",2,"c"]}`},
+        {"A.9 test failure", `{"baz":"qux"}`,
+            `[{"op":"test","path":"/baz","value":"bar"}]`,
+            ``},
+        {"A.10 add nested member", `{"foo":"bar"}`,
+            `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+            `{"foo":"bar","child":{"grandchild":{}}}`},
+        {"A.11 ignore unrecognized members", `{"foo":"bar"}`,
+            `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+            `{"foo":"bar","baz":"qux"}`},
+        {"A.12 add to nonexistent target", `{"foo":"bar"}`,
+            `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+            ``},
+        {"A.14 ~ escape ordering", `{"/":9,"~1":10}`,
+            `[{"op":"test","path":"/~01","value":10}]`,
+            `{"/":9,"~1":10}`},
+        {"A.15 compare strings and numbers", `{"/":9,"~1":10}`,
+            `[{"op":"test","path":"/~01","value":"10"}]`,
+            ``},
+        {"A.16 add array value", `{"foo":["bar"]}`,
+            `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+            `{"foo":["bar",["abc","def"]]}`},
+
+        {"~0 and ~1 escapes", `{"a/b":1,"m~n":2}`,
+            `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`,
+            `{"a/b":3}`},
+        {"- appends to an empty array", `{"a":[]}`,
+            `[{"op":"add","path":"/a/-","value":1},{"op":"add","path":"/a/-","value":2}]`,
+            `{"a":[1,2]}`},
+        {"- is not an existing element", `{"a":[1]}`,
+            `[{"op":"remove","path":"/a/-"}]`,
+            ``},
+        {"index past the end", `{"a":[1]}`,
+            `[{"op":"add","path":"/a/2","value":3}]`,
+            ``},
+        {"test missing path", `{"a":1}`,
+            `[{"op":"test","path":"/b","value":1}]`,
+            ``},
+        {"test deep equality", `{"a":{"b":[1,{"c":null}]}}`,
+            `[{"op":"test","path":"/a","value":{"b":[1,{"c":null}]}}]`,
+            `{"a":{"b":[1,{"c":null}]}}`},
+        {"failed test undoes earlier ops", `{"a":1}`,
+            `[{"op":"replace","path":"/a","value":2},{"op":"test","path":"/a","value":1}]`,
+            ``},
+        {"move into its own child", `{"a":{"b":{}}}`,
+            `[{"op":"move","from":"/a","path":"/a/b/c"}]`,
+            ``},
+        {"move to a sibling with a common prefix", `{"a":1}`,
+            `[{"op":"move","from":"/a","path":"/ab"}]`,
+            `{"ab":1}`},
+        {"copy is independent", `{"a":{"x":1}}`,
+            `[{"op":"copy","from":"/a","path":"/b"},{"op":"replace","path":"/b/x","value":2}]`,
+            `{"a":{"x":1},"b":{"x":2}}`},
+        {"replace missing path", `{"a":1}`,
+            `[{"op":"replace","path":"/b","value":1}]`,
+            ``},
+        {"replace the root", `{"a":1}`,
+            `[{"op":"replace","path":"","value":{"b":2}}]`,
+            `{"b":2}`},
+        {"add without value", `{}`,
+            `[{"op":"add","path":"/a"}]`,
+            ``},
+        {"unknown op", `{}`,
+            `[{"op":"merge","path":"/a","value":1}]`,
+            ``},
+        {"pointer without leading slash", `{"a":1}`,
+            `[{"op":"remove","path":"a"}]`,
+            ``},
+    }
+    for _, tt := range tests {
+        got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+        if tt.want == "" {
+            if err == nil {
+                t.Errorf("%s: got %s, want an error", tt.name, got)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if !jsonEqual(t, got, tt.want) {
+            t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+        }
+    }
+}